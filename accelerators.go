@@ -0,0 +1,332 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"fmt"
+	"github.com/skelterjohn/go.wde"
+	"strings"
+	"sync"
+)
+
+type Modifiers int
+
+const (
+	ModCtrl Modifiers = 1 << iota
+	ModShift
+	ModAlt
+	ModSuper
+)
+
+var modifierNames = []struct {
+	mod  Modifiers
+	name string
+}{
+	{ModCtrl, "Ctrl"},
+	{ModAlt, "Alt"},
+	{ModShift, "Shift"},
+	{ModSuper, "Super"},
+}
+
+// the names of modifier keys, as wde reports them in chords
+var modifierKeys = map[string]Modifiers{
+	wde.KeyLeftControl:  ModCtrl,
+	wde.KeyRightControl: ModCtrl,
+	wde.KeyLeftShift:    ModShift,
+	wde.KeyRightShift:   ModShift,
+	wde.KeyLeftAlt:      ModAlt,
+	wde.KeyRightAlt:     ModAlt,
+	wde.KeyLeftSuper:    ModSuper,
+	wde.KeyRightSuper:   ModSuper,
+}
+
+// what people call the keys, as well as their wde names
+var keyAliases = map[string]string{
+	"control":  "ctrl",
+	"option":   "alt",
+	"cmd":      "super",
+	"command":  "super",
+	"meta":     "super",
+	"up":       wde.KeyUpArrow,
+	"down":     wde.KeyDownArrow,
+	"left":     wde.KeyLeftArrow,
+	"right":    wde.KeyRightArrow,
+	"esc":      wde.KeyEscape,
+	"del":      wde.KeyDelete,
+	"ins":      wde.KeyInsert,
+	"pgup":     wde.KeyPrior,
+	"pageup":   wde.KeyPrior,
+	"pgdn":     wde.KeyNext,
+	"pagedown": wde.KeyNext,
+}
+
+var keyDisplayNames = map[string]string{
+	wde.KeyUpArrow:    "Up",
+	wde.KeyDownArrow:  "Down",
+	wde.KeyLeftArrow:  "Left",
+	wde.KeyRightArrow: "Right",
+	wde.KeyPrior:      "PageUp",
+	wde.KeyNext:       "PageDown",
+	wde.KeyEscape:     "Esc",
+}
+
+// A Chord is a key pressed with modifiers, such as Ctrl+Shift+S.
+type Chord struct {
+	Mods Modifiers
+	// The wde name of the key, such as "s" or "f1".
+	Key string
+}
+
+// ParseChord reads a chord written like "Ctrl+Shift+S" or "alt+f4". Case
+// doesn't matter, and keys may be given by their wde names.
+func ParseChord(s string) (c Chord, err error) {
+	for _, part := range strings.Split(s, "+") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if alias, ok := keyAliases[name]; ok {
+			name = alias
+		}
+		switch name {
+		case "ctrl":
+			c.Mods |= ModCtrl
+			continue
+		case "shift":
+			c.Mods |= ModShift
+			continue
+		case "alt":
+			c.Mods |= ModAlt
+			continue
+		case "super":
+			c.Mods |= ModSuper
+			continue
+		case "":
+			err = fmt.Errorf("accelerators: bad chord %q", s)
+			return
+		}
+		if c.Key != "" {
+			err = fmt.Errorf("accelerators: chord %q has more than one key", s)
+			return
+		}
+		c.Key = name
+	}
+	if c.Key == "" {
+		err = fmt.Errorf("accelerators: chord %q has no key", s)
+	}
+	return
+}
+
+// ChordOf returns the chord typed in e.
+func ChordOf(e KeyTypedEvent) (c Chord) {
+	for _, key := range strings.Split(e.Chord, "+") {
+		c.Mods |= modifierKeys[key]
+	}
+	if modifierKeys[e.Key] == 0 {
+		c.Key = e.Key
+	}
+	return
+}
+
+// String returns the chord as it would be shown in a menu or button, such
+// as "Ctrl+Shift+S".
+func (c Chord) String() string {
+	var parts []string
+	for _, mn := range modifierNames {
+		if c.Mods&mn.mod != 0 {
+			parts = append(parts, mn.name)
+		}
+	}
+	key, ok := keyDisplayNames[c.Key]
+	if !ok && c.Key != "" {
+		key = strings.ToUpper(c.Key[:1]) + c.Key[1:]
+	}
+	parts = append(parts, key)
+	return strings.Join(parts, "+")
+}
+
+type accelerator struct {
+	name   string
+	chord  Chord
+	scope  *Block
+	action func()
+}
+
+// Accelerators map chords to actions, which run when the chord is typed
+// whatever has the key focus. Each window has its own, whose parent is
+// Shortcuts, the app's. It is safe for use from multiple goroutines.
+type Accelerators struct {
+	mu           sync.Mutex
+	parent       *Accelerators
+	accelerators []*accelerator
+}
+
+// Shortcuts are the app's accelerators, for every window.
+var Shortcuts = NewAccelerators(nil)
+
+// NewAccelerators returns an empty registry, which falls back on parent
+// for chords it doesn't have.
+func NewAccelerators(parent *Accelerators) (a *Accelerators) {
+	a = new(Accelerators)
+	a.parent = parent
+	return
+}
+
+// Bind runs action, on its own goroutine, when chord is typed. If scope is
+// not nil, only while the key focus is on scope or a block inside it.
+// Where more than one binding of a chord applies, the one with the
+// innermost scope wins. Binding a chord twice with the same scope is an
+// error. The name is what ChordFor finds the binding by.
+func (a *Accelerators) Bind(name, chord string, scope *Block, action func()) (unbind func(), err error) {
+	c, err := ParseChord(chord)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, acc := range a.accelerators {
+		if acc.chord == c && acc.scope == scope {
+			err = fmt.Errorf("accelerators: %s is already bound to %q", c, acc.name)
+			return
+		}
+	}
+	acc := &accelerator{
+		name:   name,
+		chord:  c,
+		scope:  scope,
+		action: action,
+	}
+	a.accelerators = append(a.accelerators, acc)
+	unbind = func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i, other := range a.accelerators {
+			if other == acc {
+				a.accelerators = append(a.accelerators[:i], a.accelerators[i+1:]...)
+				break
+			}
+		}
+	}
+	return
+}
+
+// ChordFor returns the chord bound to name, here or in a parent, so that it
+// can be shown next to what it does.
+func (a *Accelerators) ChordFor(name string) (c Chord, ok bool) {
+	a.mu.Lock()
+	for _, acc := range a.accelerators {
+		if acc.name == name {
+			c, ok = acc.chord, true
+			break
+		}
+	}
+	a.mu.Unlock()
+	if !ok && a.parent != nil {
+		c, ok = a.parent.ChordFor(name)
+	}
+	return
+}
+
+// Dispatch runs the action bound to c, with the key focus on focus, and
+// says if there was one.
+func (a *Accelerators) Dispatch(c Chord, focus *Block) (handled bool) {
+	a.mu.Lock()
+	var found *accelerator
+	best := -1
+	for _, acc := range a.accelerators {
+		if acc.chord != c {
+			continue
+		}
+		depth := scopeDepth(acc.scope, focus)
+		if depth < 0 || (found != nil && depth >= best) {
+			continue
+		}
+		found, best = acc, depth
+	}
+	a.mu.Unlock()
+	if found != nil {
+		go found.action()
+		return true
+	}
+	if a.parent != nil {
+		return a.parent.Dispatch(c, focus)
+	}
+	return false
+}
+
+// scopeDepth returns how many blocks up from focus scope is, or -1 if
+// focus isn't in it. Unscoped bindings are further up than anything.
+func scopeDepth(scope, focus *Block) (depth int) {
+	if scope == nil {
+		return int(^uint(0) >> 1)
+	}
+	for b := focus; b != nil; depth++ {
+		if b == scope {
+			return
+		}
+		if b.Parent == nil {
+			break
+		}
+		b = &b.Parent.Block
+	}
+	return -1
+}
+
+// keyFocus is the innermost block with the key focus in each window, so
+// that scoped accelerators know whether they apply.
+var keyFocus = struct {
+	sync.Mutex
+	blocks map[*Block]*Block
+}{
+	blocks: map[*Block]*Block{},
+}
+
+func rootOf(b *Block) *Block {
+	for b.Parent != nil {
+		b = &b.Parent.Block
+	}
+	return b
+}
+
+// trackFocus records that b gained or lost the key focus. Blocks gain it
+// from the outside in, so the last to gain it is the innermost.
+func trackFocus(b *Block, focused bool) {
+	root := rootOf(b)
+	keyFocus.Lock()
+	defer keyFocus.Unlock()
+	switch {
+	case focused:
+		keyFocus.blocks[root] = b
+	case keyFocus.blocks[root] == b:
+		if b.Parent != nil {
+			keyFocus.blocks[root] = &b.Parent.Block
+		} else {
+			delete(keyFocus.blocks, root)
+		}
+	}
+}
+
+func focusIn(root *Block) *Block {
+	keyFocus.Lock()
+	defer keyFocus.Unlock()
+	return keyFocus.blocks[root]
+}
+
+// forgetFocus forgets the key focus of a window that has closed, so that
+// its blocks can be collected.
+func forgetFocus(root *Block) {
+	keyFocus.Lock()
+	defer keyFocus.Unlock()
+	delete(keyFocus.blocks, root)
+}
@@ -0,0 +1,153 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/go.wde"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChordRoundTrip(t *testing.T) {
+	tests := []struct {
+		text  string
+		chord Chord
+		shown string
+	}{
+		{"Ctrl+S", Chord{ModCtrl, "s"}, "Ctrl+S"},
+		{"shift + ctrl + s", Chord{ModCtrl | ModShift, "s"}, "Ctrl+Shift+S"},
+		{"alt+f4", Chord{ModAlt, "f4"}, "Alt+F4"},
+		{"Cmd+Option+Z", Chord{ModSuper | ModAlt, "z"}, "Alt+Super+Z"},
+		{"control+up", Chord{ModCtrl, wde.KeyUpArrow}, "Ctrl+Up"},
+		{"PgDn", Chord{0, wde.KeyNext}, "PageDown"},
+		{"esc", Chord{0, wde.KeyEscape}, "Esc"},
+		{"Shift+" + wde.KeyLeftArrow, Chord{ModShift, wde.KeyLeftArrow}, "Shift+Left"},
+		{"delete", Chord{0, wde.KeyDelete}, "Delete"},
+	}
+	for _, test := range tests {
+		c, err := ParseChord(test.text)
+		if err != nil {
+			t.Errorf("ParseChord(%q): %v", test.text, err)
+			continue
+		}
+		if c != test.chord {
+			t.Errorf("ParseChord(%q) = %v, want %v", test.text, c, test.chord)
+		}
+		if got := c.String(); got != test.shown {
+			t.Errorf("%v shows as %q, want %q", c, got, test.shown)
+		}
+		if again, err := ParseChord(c.String()); err != nil || again != c {
+			t.Errorf("%q parses back as %v, %v, want %v", c.String(), again, err, c)
+		}
+	}
+}
+
+func TestParseChordErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"", "bad chord"},
+		{"ctrl+", "bad chord"},
+		{"ctrl+shift", "no key"},
+		{"a+b", "more than one key"},
+	}
+	for _, test := range tests {
+		_, err := ParseChord(test.text)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseChord(%q): error %v, want one about %q", test.text, err, test.err)
+		}
+	}
+}
+
+func TestChordOf(t *testing.T) {
+	tests := []struct {
+		key, chord string
+		c          Chord
+	}{
+		{"s", "s", Chord{0, "s"}},
+		{"s", wde.KeyLeftControl + "+s", Chord{ModCtrl, "s"}},
+		{"s", wde.KeyRightControl + "+" + wde.KeyLeftShift + "+s", Chord{ModCtrl | ModShift, "s"}},
+		{"f4", wde.KeyLeftAlt + "+f4", Chord{ModAlt, "f4"}},
+		// a modifier alone is no chord
+		{wde.KeyLeftShift, wde.KeyLeftShift, Chord{ModShift, ""}},
+	}
+	for _, test := range tests {
+		var e KeyTypedEvent
+		e.Key, e.Chord = test.key, test.chord
+		if got := ChordOf(e); got != test.c {
+			t.Errorf("ChordOf(%q) = %v, want %v", test.chord, got, test.c)
+		}
+	}
+}
+
+func TestDispatchScopes(t *testing.T) {
+	outer := new(Foundation)
+	inner := new(Block)
+	inner.Parent = outer
+	other := new(Block)
+
+	ran := make(chan string, 1)
+	bind := func(a *Accelerators, name string, scope *Block) {
+		if _, err := a.Bind(name, "ctrl+s", scope, func() { ran <- name }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent := NewAccelerators(nil)
+	a := NewAccelerators(parent)
+	bind(parent, "app", nil)
+	bind(a, "window", nil)
+	bind(a, "outer", &outer.Block)
+	bind(a, "inner", inner)
+	if _, err := a.Bind("again", "Ctrl+S", inner, nil); err == nil {
+		t.Error("binding a chord twice in one scope succeeded")
+	}
+
+	c := Chord{ModCtrl, "s"}
+	tests := []struct {
+		a     *Accelerators
+		focus *Block
+		name  string
+	}{
+		{a, inner, "inner"},
+		{a, &outer.Block, "outer"},
+		{a, other, "window"},
+		{a, nil, "window"},
+		{parent, inner, "app"},
+	}
+	for _, test := range tests {
+		if !test.a.Dispatch(c, test.focus) {
+			t.Errorf("focus on %s: nothing ran", test.name)
+			continue
+		}
+		select {
+		case name := <-ran:
+			if name != test.name {
+				t.Errorf("%s ran, want %s", name, test.name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("focus on %s: nothing ran", test.name)
+		}
+	}
+	if a.Dispatch(Chord{ModCtrl, "q"}, inner) {
+		t.Error("an unbound chord was handled")
+	}
+	if got, ok := a.ChordFor("app"); !ok || got != c {
+		t.Errorf("ChordFor(app) = %v, %v, want %v", got, ok, c)
+	}
+}
@@ -0,0 +1,512 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/skelterjohn/geom"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Role says what kind of thing a block is, to assistive tools.
+type Role string
+
+const (
+	RoleGroup      Role = "group"
+	RoleWindow     Role = "window"
+	RoleButton     Role = "button"
+	RoleCheckbox   Role = "checkbox"
+	RoleRadioGroup Role = "radiogroup"
+	RoleEntry      Role = "entry"
+	RoleLabel      Role = "label"
+	RoleImage      Role = "image"
+)
+
+// leaf roles are shown as a single node. Their labels, rather than
+// appearing on their own, name them.
+var leafRoles = map[Role]bool{
+	RoleButton:   true,
+	RoleCheckbox: true,
+	RoleEntry:    true,
+	RoleLabel:    true,
+	RoleImage:    true,
+}
+
+// Accessible describes a block for assistive tools. Blocks that don't
+// describe themselves are groups.
+type Accessible struct {
+	Role  Role
+	Name  string `json:",omitempty"`
+	Value string `json:",omitempty"`
+	// Conditions particular to the kind of block, such as "checked". The
+	// block's StyleState, such as "focused", is added to these.
+	States []string `json:",omitempty"`
+	// What the block can be asked to do with an AccessActionEvent, such as
+	// "click".
+	Actions []string `json:",omitempty"`
+	// The chord that does the block's main action, such as "Ctrl+S".
+	Shortcut string `json:",omitempty"`
+}
+
+// An AccessActionEvent asks a block to do one of its Actions. Value is for
+// actions that need one, such as setting an entry's text.
+type AccessActionEvent struct {
+	Action string
+	Value  string
+}
+
+// SetAccessible publishes what the block is. Widgets call it whenever their
+// name, value or states change.
+func (b *Block) SetAccessible(a Accessible) {
+	b.accessGuard.Lock()
+	changed := !reflect.DeepEqual(a, b.access)
+	b.access = a
+	b.accessGuard.Unlock()
+	if changed {
+		Accessibility.notify(AccessChanged, b)
+	}
+}
+
+// setAccessState records the block's StyleState for assistive tools.
+func (b *Block) setAccessState(state StyleState) {
+	b.accessGuard.Lock()
+	b.accessState = state
+	b.accessGuard.Unlock()
+	Accessibility.notify(AccessChanged, b)
+}
+
+func (b *Block) accessible() (a Accessible) {
+	b.accessGuard.Lock()
+	defer b.accessGuard.Unlock()
+	a = b.access
+	if a.Role == "" {
+		a.Role = RoleGroup
+	}
+	var states []string
+	for _, ss := range styleStates {
+		if b.accessState&ss.state != 0 {
+			states = append(states, ss.name)
+		}
+	}
+	a.States = append(states, a.States...)
+	return
+}
+
+// An AccessNode is a block in a snapshot of the accessibility tree.
+type AccessNode struct {
+	ID BlockID
+	Accessible
+	// The block's bounds in its parent's coordinates. Windows have none.
+	Bounds   geom.Rect
+	Children []*AccessNode `json:",omitempty"`
+}
+
+type AccessEventKind string
+
+const (
+	AccessAdded   AccessEventKind = "added"
+	AccessRemoved AccessEventKind = "removed"
+	AccessChanged AccessEventKind = "changed"
+)
+
+// An AccessEvent tells watchers of the tree that a block was added to or
+// removed from its parent, or changed how it describes itself.
+type AccessEvent struct {
+	Kind   AccessEventKind
+	ID     BlockID
+	Parent BlockID `json:",omitempty"`
+	// What the block is now, for added and changed blocks.
+	Accessible *Accessible `json:",omitempty"`
+}
+
+// The AccessTree mirrors the hierarchy of blocks in every window, for
+// assistive tools. It is safe for use from multiple goroutines.
+type AccessTree struct {
+	mu       sync.Mutex
+	roots    map[*Block]bool
+	parents  map[*Block]*Foundation
+	children map[*Block]map[*Block]bool
+	watchers map[chan AccessEvent]bool
+}
+
+// Accessibility is the tree of every block in every window.
+var Accessibility = &AccessTree{
+	roots:    map[*Block]bool{},
+	parents:  map[*Block]*Foundation{},
+	children: map[*Block]map[*Block]bool{},
+	watchers: map[chan AccessEvent]bool{},
+}
+
+func (t *AccessTree) addRoot(b *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.roots[b] = true
+	t.send(AccessAdded, b)
+}
+
+func (t *AccessTree) removeRoot(b *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.roots[b] {
+		return
+	}
+	t.detach(b)
+	delete(t.roots, b)
+	t.send(AccessRemoved, b)
+}
+
+// An accessLink is a block and the foundation it is in.
+type accessLink struct {
+	block  *Block
+	parent *Foundation
+}
+
+// detach takes every block under b out of the tree, telling the watchers
+// that each is removed, and returns where they were, parents first. t.mu
+// must be held.
+func (t *AccessTree) detach(b *Block) (links []accessLink) {
+	for c := range t.children[b] {
+		links = append(links, accessLink{c, t.parents[c]})
+		links = append(links, t.detach(c)...)
+		t.send(AccessRemoved, c)
+		delete(t.parents, c)
+	}
+	delete(t.children, b)
+	return
+}
+
+// attach puts b in the tree, inside f. t.mu must be held.
+func (t *AccessTree) attach(b *Block, f *Foundation) {
+	t.parents[b] = f
+	if t.children[&f.Block] == nil {
+		t.children[&f.Block] = map[*Block]bool{}
+	}
+	t.children[&f.Block][b] = true
+	t.send(AccessAdded, b)
+}
+
+// link adds b to the tree inside f, along with the blocks that were under
+// it when it was last unlinked, unless they have been added elsewhere
+// since.
+func (t *AccessTree) link(b *Block, f *Foundation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.attach(b, f)
+	links := b.accessDetached
+	b.accessDetached = nil
+	for _, l := range links {
+		if _, ok := t.parents[l.block]; ok {
+			continue
+		}
+		t.attach(l.block, l.parent)
+	}
+}
+
+// unlink takes b, and everything under it, out of the tree. What was under
+// it is remembered by b, not the tree, so that it goes when b does, and
+// comes back if b is added again, as when a block is moved.
+func (t *AccessTree) unlink(b *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if links, ok := t.remove(b); ok {
+		b.accessDetached = links
+	}
+}
+
+// Forget takes b, and everything under it, out of the tree for good,
+// whether or not it has been removed from its foundation yet. It is for
+// blocks that are being thrown away.
+func (t *AccessTree) Forget(b *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remove(b)
+	b.accessDetached = nil
+}
+
+// remove takes b, and everything under it, out of the tree, and returns
+// where what was under it was, if b was in the tree. t.mu must be held.
+func (t *AccessTree) remove(b *Block) (links []accessLink, ok bool) {
+	f, ok := t.parents[b]
+	if !ok {
+		return
+	}
+	links = t.detach(b)
+	t.send(AccessRemoved, b)
+	delete(t.parents, b)
+	delete(t.children[&f.Block], b)
+	return
+}
+
+func (t *AccessTree) notify(kind AccessEventKind, b *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.roots[b] && t.parents[b] == nil {
+		// not in any window
+		return
+	}
+	t.send(kind, b)
+}
+
+// send tells the watchers about b. Watchers that aren't keeping up miss
+// events. t.mu must be held.
+func (t *AccessTree) send(kind AccessEventKind, b *Block) {
+	if len(t.watchers) == 0 {
+		return
+	}
+	e := AccessEvent{
+		Kind: kind,
+		ID:   b.ID,
+	}
+	if f := t.parents[b]; f != nil {
+		e.Parent = f.ID
+	}
+	if kind != AccessRemoved {
+		a := b.accessible()
+		e.Accessible = &a
+	}
+	for w := range t.watchers {
+		select {
+		case w <- e:
+		default:
+		}
+	}
+}
+
+// Watch returns a channel that gets an event for every change to the tree,
+// until stop is called.
+func (t *AccessTree) Watch() (events <-chan AccessEvent, stop func()) {
+	ch := make(chan AccessEvent, 64)
+	t.mu.Lock()
+	t.watchers[ch] = true
+	t.mu.Unlock()
+	events = ch
+	stop = func() {
+		t.mu.Lock()
+		delete(t.watchers, ch)
+		t.mu.Unlock()
+	}
+	return
+}
+
+// Snapshot returns the tree as it is now, with a root for each window.
+func (t *AccessTree) Snapshot() (roots []*AccessNode) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for b := range t.roots {
+		roots = append(roots, t.node(b))
+	}
+	sortAccessNodes(roots)
+	return
+}
+
+// node builds the part of the tree under b. t.mu must be held.
+func (t *AccessTree) node(b *Block) (n *AccessNode) {
+	n = &AccessNode{
+		ID:         b.ID,
+		Accessible: b.accessible(),
+	}
+	if f := t.parents[b]; f != nil {
+		n.Bounds = f.getChildPlacement(b).Bounds
+	}
+	for c := range t.children[b] {
+		n.Children = append(n.Children, t.node(c))
+	}
+	sortAccessNodes(n.Children)
+	if leafRoles[n.Role] {
+		if n.Name == "" {
+			n.Name = strings.Join(labelNames(n.Children), " ")
+		}
+		n.Children = nil
+	}
+	return
+}
+
+func labelNames(nodes []*AccessNode) (names []string) {
+	for _, n := range nodes {
+		if n.Role == RoleLabel && n.Name != "" {
+			names = append(names, n.Name)
+		}
+		names = append(names, labelNames(n.Children)...)
+	}
+	return
+}
+
+// sortAccessNodes puts nodes in reading order: top to bottom, then left to
+// right.
+func sortAccessNodes(nodes []*AccessNode) {
+	sort.Sort(accessNodeSorter(nodes))
+}
+
+type accessNodeSorter []*AccessNode
+
+func (s accessNodeSorter) Len() int      { return len(s) }
+func (s accessNodeSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s accessNodeSorter) Less(i, j int) bool {
+	bi, bj := s[i].Bounds.Min, s[j].Bounds.Min
+	if bi.Y != bj.Y {
+		return bi.Y < bj.Y
+	}
+	if bi.X != bj.X {
+		return bi.X < bj.X
+	}
+	return s[i].ID < s[j].ID
+}
+
+// WriteJSON writes a snapshot of the tree to w.
+func (t *AccessTree) WriteJSON(w io.Writer) (err error) {
+	enc := json.NewEncoder(w)
+	err = enc.Encode(t.Snapshot())
+	return
+}
+
+// DoAction asks the block with the given ID to do one of its Actions.
+func (t *AccessTree) DoAction(id BlockID, action, value string) (err error) {
+	t.mu.Lock()
+	var block *Block
+	for b := range t.roots {
+		if b.ID == id {
+			block = b
+		}
+	}
+	for b := range t.parents {
+		if b.ID == id {
+			block = b
+		}
+	}
+	t.mu.Unlock()
+	if block == nil {
+		err = fmt.Errorf("accessibility: no block %d", id)
+		return
+	}
+	for _, a := range block.accessible().Actions {
+		if a == action {
+			block.SendEvent(AccessActionEvent{
+				Action: action,
+				Value:  value,
+			})
+			return
+		}
+	}
+	err = fmt.Errorf("accessibility: block %d can't %q", id, action)
+	return
+}
+
+// An AccessRequest is what clients of Serve send, as a JSON object. Op is
+// one of
+//
+//	"tree"    reply with a snapshot of the tree
+//	"action"  ask block ID to do Action, with Value
+//	"watch"   reply with an event for every change, until the client
+//	          disconnects
+type AccessRequest struct {
+	Op     string
+	ID     BlockID
+	Action string
+	Value  string
+}
+
+// An AccessReply is what Serve sends back, as a JSON object, for each
+// request, or each event being watched.
+type AccessReply struct {
+	Tree  []*AccessNode `json:",omitempty"`
+	Event *AccessEvent  `json:",omitempty"`
+	Error string        `json:",omitempty"`
+}
+
+// Serve answers AccessRequests from clients of l, until l is closed.
+func (t *AccessTree) Serve(l net.Listener) (err error) {
+	for {
+		var conn net.Conn
+		conn, err = l.Accept()
+		if err != nil {
+			return
+		}
+		go t.serveConn(conn)
+	}
+}
+
+func (t *AccessTree) serveConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req AccessRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		var reply AccessReply
+		switch req.Op {
+		case "tree":
+			reply.Tree = t.Snapshot()
+		case "action":
+			if err := t.DoAction(req.ID, req.Action, req.Value); err != nil {
+				reply.Error = err.Error()
+			}
+		case "watch":
+			t.watch(conn, enc)
+			return
+		default:
+			reply.Error = fmt.Sprintf("accessibility: unknown op %q", req.Op)
+		}
+		if err := enc.Encode(reply); err != nil {
+			return
+		}
+	}
+}
+
+// watch sends events to the client until it goes away.
+func (t *AccessTree) watch(conn net.Conn, enc *json.Encoder) {
+	events, stop := t.Watch()
+	defer stop()
+	gone := make(chan bool)
+	go func() {
+		// the client sends nothing more, so any read ends the watch
+		conn.Read(make([]byte, 1))
+		close(gone)
+	}()
+	for {
+		select {
+		case e := <-events:
+			if err := enc.Encode(AccessReply{Event: &e}); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// ServeAccessibility answers AccessRequests on a Unix socket at path, so
+// that assistive tools and tests can inspect and drive the app. Call stop
+// to close the socket.
+func ServeAccessibility(path string) (stop func(), err error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return
+	}
+	go Accessibility.Serve(l)
+	stop = func() {
+		l.Close()
+	}
+	return
+}
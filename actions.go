@@ -0,0 +1,128 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"image"
+)
+
+// ActionState is what controls show for an Action.
+type ActionState struct {
+	Label string
+	Icon  image.Image
+	// The chord bound with BindShortcut, as it is shown, such as "Ctrl+S".
+	Shortcut string
+	Enabled  bool
+	Checked  bool
+}
+
+// An Action is something the user can do, such as saving, that one or more
+// controls offer. The controls watch it, so that disabling it, say,
+// disables all of them. It is an Observable, and safe for use from
+// multiple goroutines.
+type Action struct {
+	observable
+	handler func()
+}
+
+// NewAction returns an enabled action that runs handler when triggered.
+func NewAction(label string, handler func()) (a *Action) {
+	a = new(Action)
+	a.handler = handler
+	a.value = ActionState{
+		Label:   label,
+		Enabled: true,
+	}
+	return
+}
+
+func (a *Action) State() ActionState {
+	s, _ := a.get().(ActionState)
+	return s
+}
+
+func (a *Action) change(fn func(s *ActionState)) {
+	a.update(func(old interface{}) interface{} {
+		s, _ := old.(ActionState)
+		fn(&s)
+		return s
+	})
+}
+
+func (a *Action) SetLabel(label string) {
+	a.change(func(s *ActionState) {
+		s.Label = label
+	})
+}
+
+func (a *Action) SetIcon(icon image.Image) {
+	a.change(func(s *ActionState) {
+		s.Icon = icon
+	})
+}
+
+func (a *Action) SetEnabled(enabled bool) {
+	a.change(func(s *ActionState) {
+		s.Enabled = enabled
+	})
+}
+
+func (a *Action) SetChecked(checked bool) {
+	a.change(func(s *ActionState) {
+		s.Checked = checked
+	})
+}
+
+// Trigger runs the handler, on the calling goroutine, if the action is
+// enabled, and says whether it did.
+func (a *Action) Trigger() (ran bool) {
+	if !a.State().Enabled || a.handler == nil {
+		return
+	}
+	a.handler()
+	ran = true
+	return
+}
+
+// BindShortcut triggers the action when chord is typed, as bound in acc
+// with the action's label as its name, and makes the chord part of the
+// state, for controls to show.
+func (a *Action) BindShortcut(acc *Accelerators, chord string, scope *Block) (unbind func(), err error) {
+	c, err := ParseChord(chord)
+	if err != nil {
+		return
+	}
+	unbindChord, err := acc.Bind(a.State().Label, chord, scope, func() {
+		a.Trigger()
+	})
+	if err != nil {
+		return
+	}
+	text := c.String()
+	a.change(func(s *ActionState) {
+		s.Shortcut = text
+	})
+	unbind = func() {
+		unbindChord()
+		a.change(func(s *ActionState) {
+			if s.Shortcut == text {
+				s.Shortcut = ""
+			}
+		})
+	}
+	return
+}
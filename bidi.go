@@ -0,0 +1,349 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"unicode"
+)
+
+// Text in scripts like Arabic and Hebrew runs right to left, and may have
+// left to right text, such as numbers or English words, embedded in it.
+// The bidirectional algorithm of Unicode Standard Annex #9 decides which
+// way each run goes, and the order the runs are shown in.
+//
+// The implementation here covers implicit levels only: explicit embedding
+// and isolate controls are treated as neutral. Character properties are
+// approximated from script ranges and the unicode package's categories.
+
+// A Direction is the base direction of a paragraph of text.
+type Direction int
+
+const (
+	// The direction of the first strongly directional character, or left
+	// to right if there isn't one.
+	DirAuto Direction = iota
+	DirLTR
+	DirRTL
+)
+
+type bidiClass int
+
+const (
+	bcL bidiClass = iota
+	bcR
+	bcAL
+	bcEN
+	bcES
+	bcET
+	bcAN
+	bcCS
+	bcNSM
+	bcB
+	bcS
+	bcWS
+	bcON
+)
+
+func bidiClassOf(r rune) bidiClass {
+	switch {
+	case r == '\n' || r == '\r' || r == 0x1C || r == 0x1D || r == 0x1E ||
+		r == 0x85 || r == 0x2029:
+		return bcB
+	case r == '\t' || r == 0x0B || r == 0x1F:
+		return bcS
+	case r >= '0' && r <= '9', r >= 0x06F0 && r <= 0x06F9,
+		r >= 0x2070 && r <= 0x2079, r >= 0x2080 && r <= 0x2089,
+		r >= 0xFF10 && r <= 0xFF19:
+		return bcEN
+	case r >= 0x0660 && r <= 0x0669, r == 0x066B || r == 0x066C,
+		r >= 0x0600 && r <= 0x0605:
+		return bcAN
+	case r == '+' || r == '-' || r == 0x207A || r == 0x207B ||
+		r == 0x208A || r == 0x208B || r == 0x2212:
+		return bcES
+	case r == '#' || r == '$' || r == '%' || r == 0xB0 || r == 0xB1 ||
+		r == 0x066A || r == 0x2030 || r == 0x2031,
+		unicode.Is(unicode.Sc, r):
+		return bcET
+	case r == ',' || r == '.' || r == '/' || r == ':' || r == 0xA0 ||
+		r == 0x060C || r == 0x202F || r == 0x2044:
+		return bcCS
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return bcNSM
+	case unicode.IsSpace(r), r == 0x2028:
+		return bcWS
+	case r >= 0x0590 && r <= 0x05FF, r >= 0x07C0 && r <= 0x085F,
+		r >= 0xFB1D && r <= 0xFB4F, r >= 0x10800 && r <= 0x10FFF,
+		r >= 0x1E800 && r <= 0x1EDFF:
+		return bcR
+	case r >= 0x0600 && r <= 0x07BF, r >= 0x0860 && r <= 0x08FF,
+		r >= 0xFB50 && r <= 0xFDFF, r >= 0xFE70 && r <= 0xFEFF,
+		r >= 0x1EE00 && r <= 0x1EEFF:
+		return bcAL
+	case r == 0x200E:
+		return bcL
+	case r == 0x200F:
+		return bcR
+	case unicode.In(r, unicode.L, unicode.Mc), unicode.Is(unicode.Nd, r):
+		return bcL
+	}
+	return bcON
+}
+
+// ParagraphLevel returns the embedding level of a paragraph of runes in
+// direction dir: 0 for left to right and 1 for right to left.
+func ParagraphLevel(runes []rune, dir Direction) int {
+	switch dir {
+	case DirLTR:
+		return 0
+	case DirRTL:
+		return 1
+	}
+	for _, r := range runes {
+		switch bidiClassOf(r) {
+		case bcL:
+			return 0
+		case bcR, bcAL:
+			return 1
+		case bcB:
+			return 0
+		}
+	}
+	return 0
+}
+
+// BidiLevels returns the embedding level of each rune. Runes at odd levels
+// run right to left. Each newline starts a new paragraph, whose level is
+// found from dir.
+func BidiLevels(runes []rune, dir Direction) (levels []int) {
+	levels = make([]int, len(runes))
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && bidiClassOf(runes[i]) != bcB {
+			continue
+		}
+		base := ParagraphLevel(runes[start:i], dir)
+		resolveLevels(runes[start:i], base, levels[start:i])
+		if i < len(runes) {
+			levels[i] = base
+		}
+		start = i + 1
+	}
+	return
+}
+
+// resolveLevels applies the weak, neutral and implicit rules to one
+// paragraph.
+func resolveLevels(runes []rune, base int, levels []int) {
+	n := len(runes)
+	classes := make([]bidiClass, n)
+	for i, r := range runes {
+		classes[i] = bidiClassOf(r)
+	}
+	sos := bcL
+	if base%2 == 1 {
+		sos = bcR
+	}
+
+	// W1: marks take the class of what they are on
+	prev := sos
+	for i, c := range classes {
+		if c == bcNSM {
+			classes[i] = prev
+		}
+		prev = classes[i]
+	}
+	// W2: numbers after Arabic letters are Arabic numbers; W3: then Arabic
+	// letters are just right to left
+	strong := sos
+	for i, c := range classes {
+		switch c {
+		case bcL, bcR, bcAL:
+			strong = c
+		case bcEN:
+			if strong == bcAL {
+				classes[i] = bcAN
+			}
+		}
+	}
+	for i, c := range classes {
+		if c == bcAL {
+			classes[i] = bcR
+		}
+	}
+	// W4: a single separator between two numbers of the same kind joins them
+	for i := 1; i+1 < n; i++ {
+		before, after := classes[i-1], classes[i+1]
+		switch {
+		case classes[i] == bcES && before == bcEN && after == bcEN:
+			classes[i] = bcEN
+		case classes[i] == bcCS && before == after && (before == bcEN || before == bcAN):
+			classes[i] = before
+		}
+	}
+	// W5: terminators next to European numbers are part of them
+	for i := 0; i < n; i++ {
+		if classes[i] != bcET {
+			continue
+		}
+		j := i
+		for j < n && classes[j] == bcET {
+			j++
+		}
+		if (i > 0 && classes[i-1] == bcEN) || (j < n && classes[j] == bcEN) {
+			for k := i; k < j; k++ {
+				classes[k] = bcEN
+			}
+		}
+		i = j - 1
+	}
+	// W6: other separators and terminators are neutral
+	for i, c := range classes {
+		if c == bcES || c == bcET || c == bcCS {
+			classes[i] = bcON
+		}
+	}
+	// W7: European numbers in left to right text are left to right
+	strong = sos
+	for i, c := range classes {
+		switch c {
+		case bcL, bcR:
+			strong = c
+		case bcEN:
+			if strong == bcL {
+				classes[i] = bcL
+			}
+		}
+	}
+	// N1, N2: neutrals between two runs going the same way go that way
+	// too, and otherwise go the way of the paragraph
+	direction := func(c bidiClass) bidiClass {
+		if c == bcL {
+			return bcL
+		}
+		return bcR
+	}
+	isNeutral := func(c bidiClass) bool {
+		return c == bcB || c == bcS || c == bcWS || c == bcON
+	}
+	for i := 0; i < n; i++ {
+		if !isNeutral(classes[i]) {
+			continue
+		}
+		j := i
+		for j < n && isNeutral(classes[j]) {
+			j++
+		}
+		before, after := sos, sos
+		if i > 0 {
+			before = direction(classes[i-1])
+		}
+		if j < n {
+			after = direction(classes[j])
+		}
+		resolved := sos
+		if before == after {
+			resolved = before
+		}
+		for k := i; k < j; k++ {
+			classes[k] = resolved
+		}
+		i = j - 1
+	}
+	// I1, I2
+	for i, c := range classes {
+		levels[i] = base
+		switch {
+		case base%2 == 0 && c == bcR:
+			levels[i]++
+		case base%2 == 0 && (c == bcAN || c == bcEN):
+			levels[i] += 2
+		case base%2 == 1 && (c == bcL || c == bcEN || c == bcAN):
+			levels[i]++
+		}
+	}
+	// L1: trailing whitespace, and whitespace before tabs, goes the way of
+	// the paragraph
+	trailing := true
+	for i := n - 1; i >= 0; i-- {
+		switch c := bidiClassOf(runes[i]); {
+		case c == bcS:
+			levels[i] = base
+			trailing = true
+		case c == bcWS && trailing:
+			levels[i] = base
+		default:
+			trailing = false
+		}
+	}
+}
+
+// VisualOrder returns the logical index of the rune shown at each position,
+// from left to right, for runes at the given levels on one line.
+func VisualOrder(levels []int) (order []int) {
+	order = make([]int, len(levels))
+	highest, lowestOdd := 0, -1
+	for i, l := range levels {
+		order[i] = i
+		if l > highest {
+			highest = l
+		}
+		if l%2 == 1 && (lowestOdd == -1 || l < lowestOdd) {
+			lowestOdd = l
+		}
+	}
+	if lowestOdd == -1 {
+		return
+	}
+	// L2: reverse every run at each level or higher, from the highest
+	// level down to the lowest odd one
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(order); i++ {
+			if levels[order[i]] < level {
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return
+}
+
+var bidiMirrors = map[rune]rune{
+	'(': ')', ')': '(',
+	'<': '>', '>': '<',
+	'[': ']', ']': '[',
+	'{': '}', '}': '{',
+	0xAB: 0xBB, 0xBB: 0xAB,
+	0x2039: 0x203A, 0x203A: 0x2039,
+	0x2264: 0x2265, 0x2265: 0x2264,
+}
+
+// MirrorRune returns the glyph to show for r when it runs right to left,
+// which for brackets and the like is its mirror image.
+func MirrorRune(r rune) rune {
+	if m, ok := bidiMirrors[r]; ok {
+		return m
+	}
+	return r
+}
@@ -0,0 +1,106 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBidiLevels(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		dir    Direction
+		levels []int
+	}{
+		{"latin", "abc", DirAuto, []int{0, 0, 0}},
+		{"hebrew", "\u05D0\u05D1\u05D2", DirAuto, []int{1, 1, 1}},
+		{"latin then hebrew", "ab \u05D0\u05D1", DirAuto, []int{0, 0, 0, 1, 1}},
+		{"hebrew then latin", "\u05D0\u05D1 ab", DirAuto, []int{1, 1, 1, 2, 2}},
+		// digits in right to left text stay left to right, a level up
+		{"hebrew with digits", "\u05D0\u05D1 12", DirAuto, []int{1, 1, 1, 2, 2}},
+		{"arabic with digits", "\u0639\u062F 12", DirAuto, []int{1, 1, 1, 2, 2}},
+		{"arabic-indic digits", "\u0639\u062F \u0661\u0662", DirAuto, []int{1, 1, 1, 2, 2}},
+		{"decimal in hebrew", "\u05D0 1.5", DirAuto, []int{1, 1, 2, 2, 2}},
+		{"digits in hebrew in latin", "a \u05D0\u05D1 12", DirLTR, []int{0, 0, 1, 1, 1, 2, 2}},
+		{"forced right to left", "ab", DirRTL, []int{2, 2}},
+		{"trailing space", "ab ", DirRTL, []int{2, 2, 1}},
+		{"digits first", "12 \u05D0", DirAuto, []int{2, 2, 1, 1}},
+		{"paragraphs", "\u05D0\na", DirAuto, []int{1, 1, 0}},
+	}
+	for _, test := range tests {
+		got := BidiLevels([]rune(test.text), test.dir)
+		if !reflect.DeepEqual(got, test.levels) {
+			t.Errorf("%s: levels %v, want %v", test.name, got, test.levels)
+		}
+	}
+}
+
+func TestParagraphLevel(t *testing.T) {
+	tests := []struct {
+		text  string
+		dir   Direction
+		level int
+	}{
+		{"", DirAuto, 0},
+		{"12 ab", DirAuto, 0},
+		{"12 \u05D0", DirAuto, 1},
+		{"\u0639 ab", DirAuto, 1},
+		{"\u0639 ab", DirLTR, 0},
+		{"ab", DirRTL, 1},
+	}
+	for _, test := range tests {
+		if got := ParagraphLevel([]rune(test.text), test.dir); got != test.level {
+			t.Errorf("ParagraphLevel(%q, %d) = %d, want %d", test.text, test.dir, got, test.level)
+		}
+	}
+}
+
+func TestVisualOrder(t *testing.T) {
+	tests := []struct {
+		levels []int
+		order  []int
+	}{
+		{[]int{}, []int{}},
+		{[]int{0, 0, 0}, []int{0, 1, 2}},
+		{[]int{1, 1, 1}, []int{2, 1, 0}},
+		{[]int{0, 0, 1, 1, 1, 2, 2}, []int{0, 1, 5, 6, 4, 3, 2}},
+		{[]int{1, 1, 1, 2, 2}, []int{3, 4, 2, 1, 0}},
+	}
+	for _, test := range tests {
+		if got := VisualOrder(test.levels); !reflect.DeepEqual(got, test.order) {
+			t.Errorf("VisualOrder(%v) = %v, want %v", test.levels, got, test.order)
+		}
+	}
+}
+
+func TestMirrorRune(t *testing.T) {
+	tests := []struct {
+		r, mirror rune
+	}{
+		{'(', ')'},
+		{']', '['},
+		{'\u00AB', '\u00BB'},
+		{'a', 'a'},
+	}
+	for _, test := range tests {
+		if got := MirrorRune(test.r); got != test.mirror {
+			t.Errorf("MirrorRune(%q) = %q, want %q", test.r, got, test.mirror)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A BindingEvent runs Apply on the goroutine of the block that receives
+// it, so that Apply may change the block's state.
+type BindingEvent struct {
+	Apply func()
+}
+
+// An Observable is a value that blocks can watch. The typed values,
+// StringValue, BoolValue, IntValue, FloatValue and ListValue, are all
+// Observables.
+type Observable interface {
+	// Interface returns the current value.
+	Interface() interface{}
+	// Watch calls changed, with a BindingEvent sent to in, soon after the
+	// value is watched and after it changes. Changes that happen before
+	// changed runs are seen together, so changed should look at the
+	// latest value rather than count calls.
+	Watch(in chan<- interface{}, changed func()) (stop func())
+}
+
+type trackedBinding struct {
+	unbind func()
+}
+
+// TrackBinding remembers unbind, until it is called, so that UnbindAll
+// can call it. The returned function untracks it and calls it, once.
+// Widgets track the bindings their Bind methods make.
+func (b *Block) TrackBinding(unbind func()) (tracked func()) {
+	t := &trackedBinding{unbind}
+	b.bindingGuard.Lock()
+	if b.bindings == nil {
+		b.bindings = map[*trackedBinding]bool{}
+	}
+	b.bindings[t] = true
+	b.bindingGuard.Unlock()
+	tracked = func() {
+		b.bindingGuard.Lock()
+		ok := b.bindings[t]
+		delete(b.bindings, t)
+		b.bindingGuard.Unlock()
+		if ok {
+			t.unbind()
+		}
+	}
+	return
+}
+
+// UnbindAll calls the unbind functions of the block's tracked bindings,
+// for when the block is thrown away.
+func (b *Block) UnbindAll() {
+	b.bindingGuard.Lock()
+	bindings := b.bindings
+	b.bindings = nil
+	b.bindingGuard.Unlock()
+	for t := range bindings {
+		t.unbind()
+	}
+}
+
+type watcher struct {
+	in      chan<- interface{}
+	changed func()
+	pending bool
+}
+
+// observable is what the typed values share. It is safe for use from
+// multiple goroutines.
+type observable struct {
+	mu       sync.Mutex
+	value    interface{}
+	watchers map[*watcher]bool
+}
+
+func (o *observable) get() interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.value
+}
+
+func (o *observable) Interface() interface{} {
+	return o.get()
+}
+
+// set changes the value, and tells the watchers, unless it is the same.
+func (o *observable) set(v interface{}) {
+	o.update(func(interface{}) interface{} {
+		return v
+	})
+}
+
+// update sets the value to what fn returns, given the current value.
+func (o *observable) update(fn func(old interface{}) interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	v := fn(o.value)
+	if reflect.DeepEqual(o.value, v) {
+		return
+	}
+	o.value = v
+	for w := range o.watchers {
+		o.schedule(w)
+	}
+}
+
+// schedule sends w a BindingEvent, unless one is already on its way. o.mu
+// must be held.
+func (o *observable) schedule(w *watcher) {
+	if w.pending {
+		return
+	}
+	w.pending = true
+	e := BindingEvent{
+		Apply: func() {
+			o.mu.Lock()
+			w.pending = false
+			watching := o.watchers[w]
+			o.mu.Unlock()
+			if watching {
+				w.changed()
+			}
+		},
+	}
+	// the block's queue may be full, and the setter may be the block
+	// itself, so don't wait for it
+	go func() {
+		w.in <- e
+	}()
+}
+
+func (o *observable) Watch(in chan<- interface{}, changed func()) (stop func()) {
+	w := &watcher{
+		in:      in,
+		changed: changed,
+	}
+	o.mu.Lock()
+	if o.watchers == nil {
+		o.watchers = map[*watcher]bool{}
+	}
+	o.watchers[w] = true
+	o.schedule(w)
+	o.mu.Unlock()
+	stop = func() {
+		o.mu.Lock()
+		delete(o.watchers, w)
+		o.mu.Unlock()
+	}
+	return
+}
+
+type StringValue struct {
+	observable
+}
+
+func NewStringValue(s string) (v *StringValue) {
+	v = new(StringValue)
+	v.value = s
+	return
+}
+
+func (v *StringValue) Get() string {
+	s, _ := v.get().(string)
+	return s
+}
+
+func (v *StringValue) Set(s string) {
+	v.set(s)
+}
+
+type BoolValue struct {
+	observable
+}
+
+func NewBoolValue(b bool) (v *BoolValue) {
+	v = new(BoolValue)
+	v.value = b
+	return
+}
+
+func (v *BoolValue) Get() bool {
+	b, _ := v.get().(bool)
+	return b
+}
+
+func (v *BoolValue) Set(b bool) {
+	v.set(b)
+}
+
+type IntValue struct {
+	observable
+}
+
+func NewIntValue(i int) (v *IntValue) {
+	v = new(IntValue)
+	v.value = i
+	return
+}
+
+func (v *IntValue) Get() int {
+	i, _ := v.get().(int)
+	return i
+}
+
+func (v *IntValue) Set(i int) {
+	v.set(i)
+}
+
+type FloatValue struct {
+	observable
+}
+
+func NewFloatValue(f float64) (v *FloatValue) {
+	v = new(FloatValue)
+	v.value = f
+	return
+}
+
+func (v *FloatValue) Get() float64 {
+	f, _ := v.get().(float64)
+	return f
+}
+
+func (v *FloatValue) Set(f float64) {
+	v.set(f)
+}
+
+// A ListValue is a list of items. Get and Set copy the list, so changing
+// a list that was passed in or returned has no effect.
+type ListValue struct {
+	observable
+}
+
+func NewListValue(items ...interface{}) (v *ListValue) {
+	v = new(ListValue)
+	v.value = append([]interface{}{}, items...)
+	return
+}
+
+func (v *ListValue) Get() []interface{} {
+	items, _ := v.get().([]interface{})
+	return append([]interface{}{}, items...)
+}
+
+func (v *ListValue) Set(items []interface{}) {
+	v.set(append([]interface{}{}, items...))
+}
+
+// Update replaces the list with what fn returns, given the current list,
+// without any other change getting in between.
+func (v *ListValue) Update(fn func(items []interface{}) []interface{}) {
+	v.update(func(old interface{}) interface{} {
+		items, _ := old.([]interface{})
+		items = fn(append([]interface{}{}, items...))
+		return append([]interface{}{}, items...)
+	})
+}
+
+func (v *ListValue) Append(items ...interface{}) {
+	v.Update(func(old []interface{}) []interface{} {
+		return append(old, items...)
+	})
+}
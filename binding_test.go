@@ -0,0 +1,35 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"testing"
+)
+
+func TestUnbindAll(t *testing.T) {
+	b := new(Block)
+	calls := map[string]int{}
+	early := b.TrackBinding(func() { calls["early"]++ })
+	b.TrackBinding(func() { calls["late"]++ })
+	early()
+	early()
+	b.UnbindAll()
+	b.UnbindAll()
+	if calls["early"] != 1 || calls["late"] != 1 {
+		t.Errorf("unbinds were called %v times, want once each", calls)
+	}
+}
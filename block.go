@@ -19,7 +19,9 @@ package uik
 import (
 	"code.google.com/p/draw2d/draw2d"
 	"github.com/skelterjohn/geom"
+	"image"
 	"image/draw"
+	"sync"
 )
 
 type BlockID int
@@ -56,9 +58,14 @@ type Block struct {
 	ResizeEvents ResizeChan
 
 	Subscribe chan<- Subscription
+	events    *EventQueue
 
 	Drawer
-	buffer draw.Image
+	// the parent's copy of what this block last drew, guarded so that
+	// BlockBuffers can evict it
+	bufferGuard  sync.Mutex
+	buffer       *image.RGBA
+	effectBuffer *image.RGBA
 
 	Paint func(gc draw2d.GraphicContext)
 
@@ -72,14 +79,45 @@ type Block struct {
 
 	// size of block 
 	Size geom.Coord
+
+	// physical pixels per unit of Size
+	Scale float64
+
+	// what the block's paint functions draw with
+	Theme    *Theme
+	ownTheme bool
+
+	// what style sheet selectors match against: the kind of widget, such
+	// as "widgets.Button", a name given by the app, and the block's
+	// current state
+	Type  string
+	Name  string
+	State StyleState
+
+	// what the block publishes to the accessibility tree
+	accessGuard sync.Mutex
+	access      Accessible
+	accessState StyleState
+	// the blocks that were under this one when it was taken out of the
+	// accessibility tree, to put back if it is added again; guarded by
+	// the tree
+	accessDetached []accessLink
+
+	// the bindings made with TrackBinding, for UnbindAll
+	bindingGuard sync.Mutex
+	bindings     map[*trackedBinding]bool
 }
 
 func (b *Block) Initialize() {
 	b.ID = <-blockIDs
 
 	b.Drawer = b
+	b.Scale = 1
+	b.Theme = DefaultTheme
 
-	b.UserEventsIn, b.UserEvents, b.Subscribe = SubscriptionQueue(20)
+	b.events = NewEventQueue(20)
+	b.events.ID = b.ID
+	b.UserEventsIn, b.UserEvents, b.Subscribe = b.events.In, b.events.Out, b.events.Subscribe
 
 	b.ResizeEvents = make(ResizeChan, 1)
 	b.placementNotifications = make(placementNotificationChan, 1)
@@ -88,9 +126,53 @@ func (b *Block) Initialize() {
 	go b.handleSizeHints()
 }
 
+// SubscribeEvents sends the events that filter accepts to ch, as well as
+// handling them as usual, and returns a handle to cancel the subscription.
+// With guaranteed, events ch isn't ready for are buffered, not dropped.
+func (b *Block) SubscribeEvents(filter Filter, ch chan<- interface{}, guaranteed bool) (h *SubscriptionHandle) {
+	h = NewSubscriptionHandle()
+	b.Subscribe <- Subscription{
+		Filter:     filter,
+		Ch:         ch,
+		Guaranteed: guaranteed,
+		Handle:     h,
+	}
+	return
+}
+
+// SendEvent sends e for b to handle, unless its queue can't take it, when
+// e is dropped and counted in b's EventStats. It says whether e was sent.
+func (b *Block) SendEvent(e interface{}) (sent bool) {
+	sent = b.events.Send(e)
+	return
+}
+
+// SetEventPolicy changes what happens to events of a class that the block
+// isn't ready for.
+func (b *Block) SetEventPolicy(class EventClass, policy EventPolicy) {
+	b.events.SetPolicy(class, policy)
+}
+
+// SetFullMotion stops mouse motion being coalesced for b, and for the
+// blocks it is in, which pass motion on to it, so that b gets every point
+// the mouse passes through. Call it once b is in its window, and call it
+// again with false, before b is moved, to go back to coalescing.
+func (b *Block) SetFullMotion(full bool) {
+	b.events.setFullMotion(full)
+	for f := b.Parent; f != nil; f = f.Parent {
+		f.events.setFullMotion(full)
+	}
+}
+
+// EventStats counts what happened to the events sent to the block.
+func (b *Block) EventStats() EventStats {
+	return b.events.Stats()
+}
+
 func (b *Block) Draw(buffer draw.Image, invalidRects RectSet) {
 	// Report(b.ID, "Block.Draw()", buffer.Bounds())
 	gc := draw2d.NewGraphicContext(buffer)
+	gc.Scale(b.Scale, b.Scale)
 	b.DoPaint(gc)
 }
 
@@ -111,6 +193,20 @@ func (b *Block) HandleEvent(e interface{}) {
 	switch e := e.(type) {
 	case KeyFocusEvent:
 		b.HasKeyFocus = e.Focus
+		b.SetState(StateFocused, e.Focus)
+	case MouseEnteredEvent:
+		b.SetState(StateHover, true)
+	case MouseExitedEvent:
+		b.SetState(StateHover, false)
+	case ScaleEvent:
+		if b.Scale != e.Scale {
+			b.Scale = e.Scale
+			b.Invalidate()
+		}
+	case ThemeEvent:
+		b.applyTheme(e)
+	case BindingEvent:
+		e.Apply()
 	}
 }
 
@@ -132,6 +228,16 @@ func (b *Block) handleSizeHints() {
 	}
 }
 
+// pixelBounds is the size of the buffer the block draws into.
+func (b *Block) pixelBounds() image.Rectangle {
+	return image.Rectangle{
+		Max: image.Point{
+			int(b.Size.X*b.Scale + 0.5),
+			int(b.Size.Y*b.Scale + 0.5),
+		},
+	}
+}
+
 func (b *Block) Bounds() geom.Rect {
 	return geom.Rect{
 		geom.Coord{0, 0},
@@ -16,6 +16,11 @@
 
 package uik
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 type SizeHintChan chan SizeHint
 
 func (ch SizeHintChan) Stack(sh SizeHint) {
@@ -62,63 +67,174 @@ func (ch placementNotificationChan) Stack(e placementNotification) {
 	}
 }
 
+// A Filter decides which events a subscription gets. When done is true,
+// the subscription ends after this event.
 type Filter func(e interface{}) (accept, done bool)
 
 type Subscription struct {
+	// A nil Filter accepts every event, and never ends the subscription.
 	Filter Filter
 	Ch     chan<- interface{}
+	// If Guaranteed, events that Ch isn't ready for are buffered until it
+	// is, rather than dropped.
+	Guaranteed bool
+	// If Handle is not nil, it can cancel the subscription, and counts the
+	// events dropped. A handle is for one subscription only.
+	Handle *SubscriptionHandle
+}
+
+// A SubscriptionHandle controls a subscription after it is made.
+type SubscriptionHandle struct {
+	dropped uint64
+	end     sync.Once
+	done    chan bool
+}
+
+func NewSubscriptionHandle() (h *SubscriptionHandle) {
+	h = new(SubscriptionHandle)
+	h.done = make(chan bool)
+	return
+}
+
+// Cancel ends the subscription. No more events are sent, including
+// buffered ones.
+func (h *SubscriptionHandle) Cancel() {
+	h.finish()
+}
+
+// Done is closed when the subscription ends, because it was cancelled, its
+// Filter said it was done, or the queue was closed. Ch is never closed,
+// since it may be shared.
+func (h *SubscriptionHandle) Done() <-chan bool {
+	return h.done
+}
+
+// Dropped returns how many accepted events were dropped because Ch wasn't
+// ready for them.
+func (h *SubscriptionHandle) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+func (h *SubscriptionHandle) finish() {
+	h.end.Do(func() {
+		close(h.done)
+	})
+}
+
+func (h *SubscriptionHandle) ended() bool {
+	select {
+	case <-h.done:
+		return true
+	default:
+	}
+	return false
 }
 
 type DropChan chan<- interface{}
 
-func (ch DropChan) SendOrDrop(e interface{}) {
+// SendOrDrop sends e unless ch is full, and says whether it did. The
+// queues of blocks take events as soon as they are sent, and apply their
+// EventPolicies instead, so events are only dropped here if a sender
+// floods one faster than it can run. Block.SendEvent counts such drops.
+func (ch DropChan) SendOrDrop(e interface{}) (sent bool) {
 	select {
 	case ch <- e:
+		sent = true
 	default:
 	}
+	return
 }
 
-func SubscriptionQueue(cap int) (in chan<- interface{}, out <-chan interface{}, sub chan<- Subscription) {
-	inch := make(chan interface{}, cap)
-	mch := inch
-
-	subch := make(chan Subscription, 1)
-	outch := make(chan interface{}, 1)
-
-	go func(mch <-chan interface{}, outch chan<- interface{}, subch <-chan Subscription) {
-		subscriptions := map[*Filter]chan<- interface{}{}
-		for {
-			select {
-			case e := <-mch:
-				ok := true
-				if !ok {
-					close(outch)
-					return
-				}
-				outch <- e
-				for foo, ch := range subscriptions {
-					accept, done := (*foo)(e)
-					if accept {
-						select {
-						case ch <- e:
-						default:
-						}
-					}
-					if done {
-						delete(subscriptions, foo)
-					}
+// subscriber is a Subscription being served by a SubscriptionQueue.
+type subscriber struct {
+	Subscription
+	// for guaranteed subscriptions, what feeds the goroutine that buffers
+	feed chan interface{}
+}
+
+func newSubscriber(sub Subscription) (s *subscriber) {
+	if sub.Handle == nil {
+		sub.Handle = NewSubscriptionHandle()
+	}
+	s = &subscriber{Subscription: sub}
+	if sub.Guaranteed {
+		s.feed = make(chan interface{})
+		go s.forward()
+	}
+	return
+}
+
+func (s *subscriber) filter(e interface{}) (accept, done bool) {
+	if s.Filter == nil {
+		accept = true
+		return
+	}
+	accept, done = s.Filter(e)
+	return
+}
+
+func (s *subscriber) deliver(e interface{}) {
+	if s.feed != nil {
+		s.feed <- e
+		return
+	}
+	select {
+	case s.Ch <- e:
+	default:
+		atomic.AddUint64(&s.Handle.dropped, 1)
+	}
+}
+
+// stop is called when no more events will be delivered. Buffered events
+// are still sent, unless the subscription was cancelled.
+func (s *subscriber) stop() {
+	if s.feed != nil {
+		close(s.feed)
+		return
+	}
+	s.Handle.finish()
+}
+
+// forward sends the events fed to it on to Ch, as fast as Ch takes them,
+// buffering the rest.
+func (s *subscriber) forward() {
+	defer s.Handle.finish()
+	var pending []interface{}
+	feed := s.feed
+	for feed != nil || len(pending) != 0 {
+		var out chan<- interface{}
+		var next interface{}
+		if len(pending) != 0 {
+			out, next = s.Ch, pending[0]
+		}
+		select {
+		case e, ok := <-feed:
+			if !ok {
+				feed = nil
+				break
+			}
+			pending = append(pending, e)
+		case out <- next:
+			pending[0] = nil
+			pending = pending[1:]
+		case <-s.Handle.done:
+			// cancelled, so the queue may still feed us until it notices
+			if feed != nil {
+				for _ = range feed {
 				}
-			case sub := <-subch:
-				subscriptions[&sub.Filter] = sub.Ch
 			}
-
+			return
 		}
-	}(mch, outch, subch)
-
-	in = inch
-	out = outch
-	sub = subch
+	}
+}
 
+// SubscriptionQueue passes what is sent on in to out, and to every
+// subscription whose filter accepts it. When in is closed, out is closed
+// and the subscriptions end. See EventQueue for what happens to events
+// that out isn't ready for.
+func SubscriptionQueue(cap int) (in chan<- interface{}, out <-chan interface{}, sub chan<- Subscription) {
+	q := NewEventQueue(cap)
+	in, out, sub = q.In, q.Out, q.Subscribe
 	return
 }
 
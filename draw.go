@@ -64,7 +64,7 @@ func LookupPaint(path string, x interface{}) (pf PaintFunc) {
 func windowPaintGen(x interface{}) (pf PaintFunc) {
 	wf := x.(*WindowFoundation)
 	return func(gc draw2d.GraphicContext) {
-		gc.SetFillColor(color.White)
+		gc.SetFillColor(wf.StyleColor("background"))
 		draw2d.Rect(gc, 0, 0, wf.Size.X, wf.Size.Y)
 		gc.Fill()
 	}
@@ -0,0 +1,243 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// A BlendMode decides how a child's colors combine with what is already
+// drawn underneath it. They follow the W3C compositing definitions, and only
+// apply when the Foundation's DrawOp is draw.Over.
+type BlendMode int
+
+const (
+	BlendNormal BlendMode = iota
+	BlendMultiply
+	BlendScreen
+	BlendOverlay
+	BlendDarken
+	BlendLighten
+	BlendAdd
+	BlendDifference
+)
+
+// blend mixes the unpremultiplied source and destination channels, in [0, 1].
+func (m BlendMode) blend(cs, cd float64) float64 {
+	switch m {
+	case BlendMultiply:
+		return cs * cd
+	case BlendScreen:
+		return cs + cd - cs*cd
+	case BlendOverlay:
+		if cd <= 0.5 {
+			return 2 * cs * cd
+		}
+		cd = 2*cd - 1
+		return cs + cd - cs*cd
+	case BlendDarken:
+		return math.Min(cs, cd)
+	case BlendLighten:
+		return math.Max(cs, cd)
+	case BlendAdd:
+		return math.Min(1, cs+cd)
+	case BlendDifference:
+		return math.Abs(cs - cd)
+	}
+	return cs
+}
+
+// composite puts the premultiplied source pixel over the destination pixel,
+// with channels in [0, 255].
+func (m BlendMode) composite(sr, sg, sb, sa, dr, dg, db, da float64) (r, g, b, a float64) {
+	if m == BlendNormal || da == 0 {
+		f := 1 - sa/255
+		return sr + dr*f, sg + dg*f, sb + db*f, sa + da*f
+	}
+	fs, fd := sa/255, da/255
+	channel := func(sc, dc float64) float64 {
+		var cs, cd float64
+		if sa > 0 {
+			cs = sc / sa
+		}
+		cd = dc / da
+		return (1-fd)*sc + (1-fs)*dc + fs*fd*255*m.blend(cs, cd)
+	}
+	r = channel(sr, dr)
+	g = channel(sg, dg)
+	b = channel(sb, db)
+	a = sa + da - sa*fd
+	return
+}
+
+// An Effect is applied to a child's buffer before it is composited onto
+// its parent.
+type Effect interface {
+	// How far past the edges of the block the effect may draw.
+	Margin() float64
+	// Apply modifies buf in place. The block's own drawing is found
+	// within buf, inset by the combined margins of all its effects.
+	// The buffer has scale physical pixels per unit.
+	Apply(buf *image.RGBA, scale float64)
+}
+
+// Blur is a gaussian blur whose kernel reaches Radius units.
+type Blur struct {
+	Radius float64
+}
+
+func (b Blur) Margin() float64 {
+	return b.Radius
+}
+
+func (b Blur) Apply(buf *image.RGBA, scale float64) {
+	gaussianBlur(buf, b.Radius*scale)
+}
+
+// DropShadow draws a blurred copy of the block's silhouette beneath it.
+type DropShadow struct {
+	Offset geom.Coord
+	Radius float64
+	Color  color.Color
+}
+
+func (d DropShadow) Margin() float64 {
+	return d.Radius + math.Max(math.Abs(d.Offset.X), math.Abs(d.Offset.Y))
+}
+
+func (d DropShadow) Apply(buf *image.RGBA, scale float64) {
+	c := d.Color
+	if c == nil {
+		c = color.RGBA{0, 0, 0, 128}
+	}
+	cr, cg, cb, ca := c.RGBA()
+
+	bounds := buf.Bounds()
+	shadow := Buffers.Get(bounds.Dx(), bounds.Dy())
+	defer Buffers.Put(shadow)
+
+	ox, oy := int(d.Offset.X*scale), int(d.Offset.Y*scale)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			sx, sy := x-bounds.Min.X+ox, y-bounds.Min.Y+oy
+			if sx < 0 || sy < 0 || sx >= bounds.Dx() || sy >= bounds.Dy() {
+				continue
+			}
+			a := uint32(buf.Pix[buf.PixOffset(x, y)+3])
+			if a == 0 {
+				continue
+			}
+			i := shadow.PixOffset(sx, sy)
+			shadow.Pix[i+0] = uint8(cr * a / 255 >> 8)
+			shadow.Pix[i+1] = uint8(cg * a / 255 >> 8)
+			shadow.Pix[i+2] = uint8(cb * a / 255 >> 8)
+			shadow.Pix[i+3] = uint8(ca * a / 255 >> 8)
+		}
+	}
+	gaussianBlur(shadow, d.Radius*scale)
+
+	draw.Draw(shadow, shadow.Bounds(), buf, bounds.Min, draw.Over)
+	draw.Draw(buf, bounds, shadow, image.Point{0, 0}, draw.Src)
+}
+
+func effectsMargin(effects []Effect) (margin float64) {
+	for _, e := range effects {
+		margin += e.Margin()
+	}
+	return
+}
+
+// applyEffects returns a copy of src with effects applied, positioned so
+// that src's pixels keep their coordinates. src has scale physical pixels
+// per unit.
+func applyEffects(src *image.RGBA, effects []Effect, scale float64) (dst *image.RGBA) {
+	m := int(math.Ceil(effectsMargin(effects) * scale))
+	sb := src.Bounds()
+	dst = Buffers.Get(sb.Dx()+2*m, sb.Dy()+2*m)
+	draw.Draw(dst, sb.Add(image.Point{m, m}), src, sb.Min, draw.Src)
+	for _, e := range effects {
+		e.Apply(dst, scale)
+	}
+	dst.Rect = dst.Rect.Sub(image.Point{m, m})
+	return
+}
+
+// gaussianBlur blurs buf in place, with a kernel reaching radius pixels.
+func gaussianBlur(buf *image.RGBA, radius float64) {
+	kr := int(math.Ceil(radius))
+	if kr < 1 {
+		return
+	}
+	sigma := radius / 3
+	kernel := make([]float64, 2*kr+1)
+	var total float64
+	for i := range kernel {
+		x := float64(i - kr)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	b := buf.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tmp := make([]float64, w*h*4)
+
+	// horizontal pass, from buf into tmp
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc [4]float64
+			for k, kv := range kernel {
+				sx := x + k - kr
+				if sx < 0 || sx >= w {
+					continue
+				}
+				o := buf.PixOffset(b.Min.X+sx, b.Min.Y+y)
+				for c := 0; c < 4; c++ {
+					acc[c] += kv * float64(buf.Pix[o+c])
+				}
+			}
+			copy(tmp[(y*w+x)*4:], acc[:])
+		}
+	}
+
+	// vertical pass, from tmp back into buf
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var acc [4]float64
+			for k, kv := range kernel {
+				sy := y + k - kr
+				if sy < 0 || sy >= h {
+					continue
+				}
+				t := (sy*w + x) * 4
+				for c := 0; c < 4; c++ {
+					acc[c] += kv * tmp[t+c]
+				}
+			}
+			o := buf.PixOffset(b.Min.X+x, b.Min.Y+y)
+			for c := 0; c < 4; c++ {
+				buf.Pix[o+c] = clamp8(acc[c])
+			}
+		}
+	}
+}
@@ -0,0 +1,290 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"reflect"
+	"sync"
+)
+
+// An EventClass groups events that are treated alike when a block falls
+// behind.
+type EventClass int
+
+const (
+	// Anything not in another class, such as ThemeEvents and
+	// BindingEvents.
+	ClassOther EventClass = iota
+	// Mouse buttons, keys, the mouse entering and leaving, and closing.
+	ClassInput
+	// The mouse moving or dragging.
+	ClassMotion
+)
+
+// ClassOf returns the class of an event.
+func ClassOf(e interface{}) EventClass {
+	switch e.(type) {
+	case MouseMovedEvent, MouseDraggedEvent:
+		return ClassMotion
+	case MouseDownEvent, MouseUpEvent, MouseEnteredEvent, MouseExitedEvent,
+		KeyDownEvent, KeyUpEvent, KeyTypedEvent, CloseEvent:
+		return ClassInput
+	}
+	return ClassOther
+}
+
+// An EventPolicy says what an EventQueue does with events of a class that
+// its block isn't ready for.
+type EventPolicy int
+
+const (
+	// Keep them until the block is ready, however many there are.
+	PolicyLossless EventPolicy = iota
+	// Merge with the last waiting event, if it is of the same kind. For
+	// mouse motion, the merged event goes from where the first one started
+	// to where the last one ended.
+	PolicyCoalesce
+	// Drop them once as many are waiting as the queue's capacity.
+	PolicyDrop
+)
+
+// The policies queues start with. Apps that need every point the mouse
+// passes through, such as for drawing, can set ClassMotion to
+// PolicyLossless before making any windows, or use Block.SetFullMotion.
+var DefaultEventPolicies = map[EventClass]EventPolicy{
+	ClassOther:  PolicyLossless,
+	ClassInput:  PolicyLossless,
+	ClassMotion: PolicyCoalesce,
+}
+
+// EventStats counts what happened to the events sent to a queue.
+type EventStats struct {
+	Received  uint64
+	Delivered uint64
+	// By PolicyDrop, or by Send because In was full.
+	Dropped   uint64
+	Coalesced uint64
+	// The events waiting now, and the most there have ever been.
+	Backlog, MaxBacklog int
+}
+
+// FallingBehind, if not nil, is called whenever the backlog of a block's
+// queue reaches the queue's capacity, after having been empty. It is
+// called on its own goroutine.
+var FallingBehind func(id BlockID, stats EventStats)
+
+// An EventQueue passes the events sent on In to Out, and to the
+// subscriptions made with Subscribe. Events that Out isn't ready for wait
+// in a backlog, according to the policy for their class. When In is
+// closed, the backlog is sent, Out is closed and the subscriptions end.
+type EventQueue struct {
+	In        chan<- interface{}
+	Out       <-chan interface{}
+	Subscribe chan<- Subscription
+
+	// The block the queue belongs to, for FallingBehind. Set it before
+	// sending any events.
+	ID BlockID
+
+	mu       sync.Mutex
+	cap      int
+	policies map[EventClass]EventPolicy
+	stats    EventStats
+	alerted  bool
+	// how many blocks, this one or inside it, want full motion
+	fullMotion int
+}
+
+func NewEventQueue(cap int) (q *EventQueue) {
+	q = new(EventQueue)
+	q.cap = cap
+	q.policies = map[EventClass]EventPolicy{}
+	for class, policy := range DefaultEventPolicies {
+		q.policies[class] = policy
+	}
+
+	inch := make(chan interface{}, cap)
+	subch := make(chan Subscription, 1)
+	outch := make(chan interface{}, 1)
+	q.In, q.Out, q.Subscribe = inch, outch, subch
+
+	go q.run(inch, outch, subch)
+	return
+}
+
+// SetPolicy changes what happens to events of a class that the block
+// isn't ready for. Events already waiting are not affected.
+func (q *EventQueue) SetPolicy(class EventClass, policy EventPolicy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policies[class] = policy
+}
+
+// setFullMotion counts a block that wants every motion event, or no
+// longer does.
+func (q *EventQueue) setFullMotion(full bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if full {
+		q.fullMotion++
+	} else if q.fullMotion > 0 {
+		q.fullMotion--
+	}
+}
+
+// Send sends e on In, unless In is full, when e is dropped and counted in
+// the stats. It says whether e was sent.
+func (q *EventQueue) Send(e interface{}) (sent bool) {
+	select {
+	case q.In <- e:
+		sent = true
+	default:
+		q.mu.Lock()
+		q.stats.Received++
+		q.stats.Dropped++
+		q.mu.Unlock()
+	}
+	return
+}
+
+func (q *EventQueue) Stats() (stats EventStats) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats = q.stats
+	return
+}
+
+func (q *EventQueue) run(in <-chan interface{}, out chan<- interface{}, subch <-chan Subscription) {
+	subscriptions := map[*subscriber]bool{}
+	defer func() {
+		close(out)
+		for s := range subscriptions {
+			s.stop()
+		}
+	}()
+	var backlog []interface{}
+	for {
+		var outch chan<- interface{}
+		var next interface{}
+		if len(backlog) != 0 {
+			outch, next = out, backlog[0]
+		} else if in == nil {
+			return
+		}
+		select {
+		case e, ok := <-in:
+			if !ok {
+				in = nil
+				break
+			}
+			for s := range subscriptions {
+				if s.Handle.ended() {
+					delete(subscriptions, s)
+					s.stop()
+					continue
+				}
+				accept, done := s.filter(e)
+				if accept {
+					s.deliver(e)
+				}
+				if done {
+					delete(subscriptions, s)
+					s.stop()
+				}
+			}
+			backlog = q.enqueue(backlog, e)
+		case outch <- next:
+			backlog[0] = nil
+			backlog = backlog[1:]
+			q.mu.Lock()
+			q.stats.Delivered++
+			q.stats.Backlog = len(backlog)
+			if len(backlog) == 0 {
+				q.alerted = false
+			}
+			q.mu.Unlock()
+		case sub := <-subch:
+			subscriptions[newSubscriber(sub)] = true
+		}
+	}
+}
+
+// enqueue adds e to the backlog, or not, according to its policy.
+func (q *EventQueue) enqueue(backlog []interface{}, e interface{}) []interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.stats.Received++
+	policy := q.policies[ClassOf(e)]
+	if policy == PolicyCoalesce && q.fullMotion > 0 && ClassOf(e) == ClassMotion {
+		policy = PolicyLossless
+	}
+	switch policy {
+	case PolicyCoalesce:
+		if n := len(backlog); n != 0 {
+			if merged, ok := coalesce(backlog[n-1], e); ok {
+				backlog[n-1] = merged
+				q.stats.Coalesced++
+				return backlog
+			}
+		}
+	case PolicyDrop:
+		if len(backlog) >= q.cap {
+			q.stats.Dropped++
+			return backlog
+		}
+	}
+	backlog = append(backlog, e)
+	q.stats.Backlog = len(backlog)
+	if len(backlog) > q.stats.MaxBacklog {
+		q.stats.MaxBacklog = len(backlog)
+	}
+	if len(backlog) >= q.cap && !q.alerted {
+		q.alerted = true
+		if alert := FallingBehind; alert != nil {
+			go alert(q.ID, q.stats)
+		}
+	}
+	return backlog
+}
+
+// coalesce merges e into the event before it, if they are of the same
+// kind. Motion keeps where it came from, and takes where it went.
+func coalesce(prev, e interface{}) (merged interface{}, ok bool) {
+	switch e := e.(type) {
+	case MouseMovedEvent:
+		p, isMove := prev.(MouseMovedEvent)
+		if !isMove {
+			return
+		}
+		e.From = p.From
+		e.MouseMovedEvent.From = p.MouseMovedEvent.From
+		merged, ok = e, true
+	case MouseDraggedEvent:
+		p, isDrag := prev.(MouseDraggedEvent)
+		if !isDrag || p.Which != e.Which {
+			return
+		}
+		e.From = p.From
+		e.MouseDraggedEvent.From = p.MouseDraggedEvent.From
+		merged, ok = e, true
+	default:
+		if reflect.TypeOf(prev) == reflect.TypeOf(e) {
+			merged, ok = e, true
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/geom"
+	"github.com/skelterjohn/go.wde"
+	"testing"
+)
+
+func move(from, to float64) (e MouseMovedEvent) {
+	e.From = geom.Coord{from, 0}
+	e.Loc = geom.Coord{to, 0}
+	return
+}
+
+func drag(which wde.Button, from, to float64) (e MouseDraggedEvent) {
+	e.Which = which
+	e.From = geom.Coord{from, 0}
+	e.Loc = geom.Coord{to, 0}
+	return
+}
+
+func TestEventQueueCoalescing(t *testing.T) {
+	tests := []struct {
+		name     string
+		cap      int
+		policies map[EventClass]EventPolicy
+		full     bool
+		events   []interface{}
+		// what is left waiting, and what happened to the rest
+		backlog, coalesced, dropped int
+	}{
+		{
+			name:      "moves",
+			cap:       8,
+			events:    []interface{}{move(0, 1), move(1, 2), move(2, 3)},
+			backlog:   1,
+			coalesced: 2,
+		},
+		{
+			name:    "moves around a click",
+			cap:     8,
+			events:  []interface{}{move(0, 1), MouseDownEvent{}, move(1, 2)},
+			backlog: 3,
+		},
+		{
+			name:      "drags",
+			cap:       8,
+			events:    []interface{}{drag(wde.LeftButton, 0, 1), drag(wde.LeftButton, 1, 2), drag(wde.LeftButton, 2, 3)},
+			backlog:   1,
+			coalesced: 2,
+		},
+		{
+			name:    "drags with different buttons",
+			cap:     8,
+			events:  []interface{}{drag(wde.LeftButton, 0, 1), drag(wde.RightButton, 1, 2)},
+			backlog: 2,
+		},
+		{
+			name:      "a move then drags",
+			cap:       8,
+			events:    []interface{}{move(0, 1), drag(wde.LeftButton, 1, 2), drag(wde.LeftButton, 2, 3)},
+			backlog:   2,
+			coalesced: 1,
+		},
+		{
+			name:     "lossless motion",
+			cap:      8,
+			policies: map[EventClass]EventPolicy{ClassMotion: PolicyLossless},
+			events:   []interface{}{move(0, 1), move(1, 2), move(2, 3)},
+			backlog:  3,
+		},
+		{
+			name:    "full motion",
+			cap:     8,
+			full:    true,
+			events:  []interface{}{move(0, 1), move(1, 2), move(2, 3)},
+			backlog: 3,
+		},
+		{
+			name:     "dropping",
+			cap:      2,
+			policies: map[EventClass]EventPolicy{ClassOther: PolicyDrop},
+			events:   []interface{}{ThemeEvent{}, ThemeEvent{}, ThemeEvent{}, LocaleEvent{}},
+			backlog:  2,
+			dropped:  2,
+		},
+		{
+			name:      "coalescing others of a kind",
+			cap:       8,
+			policies:  map[EventClass]EventPolicy{ClassOther: PolicyCoalesce},
+			events:    []interface{}{ThemeEvent{}, ThemeEvent{}, LocaleEvent{}, LocaleEvent{}, ThemeEvent{}},
+			backlog:   3,
+			coalesced: 2,
+		},
+		{
+			name:    "input is never coalesced",
+			cap:     8,
+			events:  []interface{}{MouseDownEvent{}, MouseDownEvent{}, KeyTypedEvent{}, KeyTypedEvent{}},
+			backlog: 4,
+		},
+	}
+	for _, test := range tests {
+		q := NewEventQueue(test.cap)
+		for class, policy := range test.policies {
+			q.SetPolicy(class, policy)
+		}
+		q.setFullMotion(test.full)
+		var backlog []interface{}
+		for _, e := range test.events {
+			backlog = q.enqueue(backlog, e)
+		}
+		stats := q.Stats()
+		if len(backlog) != test.backlog || stats.Coalesced != uint64(test.coalesced) || stats.Dropped != uint64(test.dropped) {
+			t.Errorf("%s: %d waiting, %d coalesced, %d dropped; want %d, %d, %d", test.name,
+				len(backlog), stats.Coalesced, stats.Dropped, test.backlog, test.coalesced, test.dropped)
+		}
+		if stats.Received != uint64(len(test.events)) {
+			t.Errorf("%s: %d received, want %d", test.name, stats.Received, len(test.events))
+		}
+		close(q.In)
+	}
+}
+
+func TestCoalescedMotionSpans(t *testing.T) {
+	merged, ok := coalesce(move(0, 1), move(1, 5))
+	if !ok {
+		t.Fatal("moves didn't coalesce")
+	}
+	m := merged.(MouseMovedEvent)
+	if m.From.X != 0 || m.Loc.X != 5 {
+		t.Errorf("merged move goes from %v to %v, want 0 to 5", m.From.X, m.Loc.X)
+	}
+	if _, ok := coalesce(MouseDownEvent{}, move(0, 1)); ok {
+		t.Error("a move coalesced with a click")
+	}
+}
+
+func TestEventQueueAccounts(t *testing.T) {
+	q := NewEventQueue(4)
+	const n = 100
+	for i := 0; i < n; i++ {
+		q.In <- move(float64(i), float64(i+1))
+	}
+	close(q.In)
+	delivered := 0
+	var last MouseMovedEvent
+	for e := range q.Out {
+		last = e.(MouseMovedEvent)
+		delivered++
+	}
+	stats := q.Stats()
+	if stats.Received != n || stats.Delivered != uint64(delivered) || stats.Delivered+stats.Coalesced != n {
+		t.Errorf("%d sent, stats %+v, %d delivered", n, stats, delivered)
+	}
+	if last.Loc.X != n {
+		t.Errorf("the last move ends at %v, want %d", last.Loc.X, n)
+	}
+}
@@ -109,6 +109,11 @@ type Invalidation struct {
 
 type SizeHint struct {
 	MinSize, PreferredSize, MaxSize geom.Coord
+	// If not nil, the height the block would like to have when given a
+	// particular width, such as for wrapping text. Grids and flows ask
+	// it for the height at the width they give the block. Blocks that
+	// provide it also update PreferredSize whenever they are resized.
+	HeightForWidth func(width float64) float64
 }
 
 type placementNotification struct {
@@ -127,3 +132,23 @@ type KeyFocusEvent struct {
 type ResizeEvent struct {
 	Size geom.Coord
 }
+
+// A ThemeEvent changes the theme a block paints with.
+type ThemeEvent struct {
+	Theme *Theme
+	// Inherited is true when the theme is being passed down from an
+	// ancestor. Blocks that were given their own theme ignore these.
+	Inherited bool
+}
+
+// A LocaleEvent tells a block that Translations has changed locale, so
+// that it can show its text in the new one.
+type LocaleEvent struct {
+	Locale string
+}
+
+// A ScaleEvent tells a block how many physical pixels make up one unit of
+// its coordinates.
+type ScaleEvent struct {
+	Scale float64
+}
@@ -53,11 +53,10 @@ func uikplay() {
 	rg := widgets.NewRadio([]string{"bread", "cake", "beheadings"})
 	ge.AddName("radio", &rg.Block)
 
-	l := widgets.NewLabel(geom.Coord{100, 30}, widgets.LabelConfig{"text", 14, color.Black})
+	l := widgets.NewLabel(geom.Coord{100, 30}, widgets.LabelConfig{Text: "text", FontSize: 14, Color: color.Black})
 	ge.AddName("label", &layouts.NewPadBox(layouts.PadConfig{Right: 10}, &l.Block).Block)
 
-	selLis := make(widgets.SelectionListener, 1)
-	rg.AddSelectionListener <- selLis
+	selLis := rg.Selected.Listen(uik.DeliverLatest, 1)
 
 	w.SetPane(&g.Block)
 
@@ -69,12 +68,12 @@ func uikplay() {
 		done = accept
 		return
 	}
-	w.Block.Subscribe <- uik.Subscription{isDone, done}
+	w.Block.Subscribe <- uik.Subscription{Filter: isDone, Ch: done}
 
 loop:
 	for {
 		select {
-		case sel := <-selLis:
+		case sel := <-selLis.C:
 			l.SetConfig(widgets.LabelConfig{
 				Text:     fmt.Sprintf("Clicked option %d, %q", sel.Index, sel.Option),
 				FontSize: 14,
@@ -36,30 +36,28 @@ func uiktest() {
 	// we modify the copy for a special message to display
 	ld.Text = "clicked!"
 
-	l := widgets.NewLabel(geom.Coord{100, 50}, widgets.LabelConfig{"text", 14, color.Black})
+	l := widgets.NewLabel(geom.Coord{100, 50}, widgets.LabelConfig{Text: "text", FontSize: 14, Color: color.Black})
 	b2 := widgets.NewButton("there")
 	ld2 := b2.Label.GetConfig()
 	ld2.Text = "BAM"
 
-	// the widget.Buttton has a special channels that sends out wde.Buttons
-	// whenever its clicked. Here we set up something that changes the
-	// label's text every time a click is received.
-	clicker := make(widgets.Clicker)
-	b.AddClicker <- clicker
+	// the widget.Buttton has a signal that sends out wde.Buttons whenever
+	// its clicked. Here we set up something that changes the label's text
+	// every time a click is received.
+	clicker := b.Clicked.Listen(uik.DeliverDrop, 1)
 	go func() {
-		for _ = range clicker {
+		for _ = range clicker.C {
 			b.Label.SetConfig(ld)
-			l.SetConfig(widgets.LabelConfig{"ohnoes", 20, color.Black})
+			l.SetConfig(widgets.LabelConfig{Text: "ohnoes", FontSize: 20, Color: color.Black})
 		}
 	}()
 
-	clicker2 := make(widgets.Clicker)
-	b2.AddClicker <- clicker2
+	clicker2 := b2.Clicked.Listen(uik.DeliverDrop, 1)
 	go func() {
-		for _ = range clicker2 {
+		for _ = range clicker2.C {
 			b.Label.SetConfig(ld2)
 			b2.Label.SetConfig(ld)
-			l.SetConfig(widgets.LabelConfig{"oops", 14, color.Black})
+			l.SetConfig(widgets.LabelConfig{Text: "oops", FontSize: 14, Color: color.Black})
 		}
 	}()
 
@@ -109,10 +107,10 @@ func uiktest() {
 	ge := layouts.NewGridEngine(gconfig)
 	g := layouts.NewLayouter(ge)
 
-	l0_0 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{"0, 0", 12, color.Black})
-	l0_1 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{"0, 1", 12, color.Black})
-	l1_0 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{"1, 0", 12, color.Black})
-	l1_1 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{"1, 1", 12, color.Black})
+	l0_0 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{Text: "0, 0", FontSize: 12, Color: color.Black})
+	l0_1 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{Text: "0, 1", FontSize: 12, Color: color.Black})
+	l1_0 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{Text: "1, 0", FontSize: 12, Color: color.Black})
+	l1_1 := widgets.NewLabel(geom.Coord{}, widgets.LabelConfig{Text: "1, 1", FontSize: 12, Color: color.Black})
 
 	ge.AddName("ul", &l0_0.Block)
 	ge.AddName("ll", &l0_1.Block)
@@ -137,18 +135,16 @@ func uiktest() {
 		}
 	}
 
-	clicker3 := make(widgets.Clicker)
-	b.AddClicker <- clicker3
+	clicker3 := b.Clicked.Listen(uik.DeliverDrop, 1)
 	go func() {
-		for _ = range clicker3 {
-			l0_0.SetConfig(widgets.LabelConfig{"Pow", 12, color.Black})
+		for _ = range clicker3.C {
+			l0_0.SetConfig(widgets.LabelConfig{Text: "Pow", FontSize: 12, Color: color.Black})
 		}
 	}()
-	clicker4 := make(widgets.Clicker)
-	b2.AddClicker <- clicker4
+	clicker4 := b2.Clicked.Listen(uik.DeliverDrop, 1)
 	go func() {
-		for _ = range clicker4 {
-			l0_0.SetConfig(widgets.LabelConfig{"gotcha", 12, color.Black})
+		for _ = range clicker4.C {
+			l0_0.SetConfig(widgets.LabelConfig{Text: "gotcha", FontSize: 12, Color: color.Black})
 		}
 	}()
 
@@ -181,7 +177,7 @@ func uiktest() {
 		done = accept
 		return
 	}
-	w.Block.Subscribe <- uik.Subscription{isDone, done}
+	w.Block.Subscribe <- uik.Subscription{Filter: isDone, Ch: done}
 
 	// once a close event comes in on the subscription, end the program
 	<-done
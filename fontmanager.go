@@ -0,0 +1,360 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	WeightLight  = 300
+	WeightNormal = 400
+	WeightBold   = 700
+)
+
+// A FontFace is one font of a family, in a particular weight and slant.
+type FontFace struct {
+	Family string
+	Weight int
+	Italic bool
+	Font   *truetype.Font
+	// The file the face was loaded from, if any.
+	Path string
+	// What draw2d knows the face as. Give it to a GraphicContext, a
+	// Theme or RenderString to draw with the face.
+	Data draw2d.FontData
+}
+
+// HasGlyph reports whether the face can draw r.
+func (ff *FontFace) HasGlyph(r rune) bool {
+	return ff.Font.Index(r) != 0
+}
+
+// A FontManager keeps track of the available fonts, finds the face that
+// best matches a family, weight and slant, and picks substitutes for glyphs
+// a face lacks.
+type FontManager struct {
+	guard sync.RWMutex
+	faces []*FontFace
+	// by the data draw2d knows them by
+	byData map[draw2d.FontData]*FontFace
+	// family key -> families to try, in order, for missing glyphs
+	fallbacks map[string][]string
+	// tried after a family's own fallbacks
+	defaultFallbacks []string
+}
+
+func NewFontManager() (fm *FontManager) {
+	fm = new(FontManager)
+	fm.byData = map[draw2d.FontData]*FontFace{}
+	fm.fallbacks = map[string][]string{}
+	return
+}
+
+// Fonts is the FontManager that widgets draw text with. It starts out
+// knowing only the embedded Luxi Sans, as DefaultFontData.
+var Fonts = NewFontManager()
+
+// familyKey makes family names comparable: "DejaVu Sans Mono" and
+// "dejavusansmono" are the same family.
+func familyKey(family string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return -1
+	}, family)
+}
+
+// parseSubfamily guesses the weight and slant from a subfamily name such
+// as "Bold Italic" or "Light".
+func parseSubfamily(sub string) (weight int, italic bool) {
+	sub = strings.ToLower(sub)
+	weight = WeightNormal
+	italic = strings.Contains(sub, "italic") || strings.Contains(sub, "oblique")
+	weights := []struct {
+		name   string
+		weight int
+	}{
+		{"thin", 100},
+		{"extralight", 200},
+		{"ultralight", 200},
+		{"light", WeightLight},
+		{"medium", 500},
+		{"semibold", 600},
+		{"demibold", 600},
+		{"extrabold", 800},
+		{"ultrabold", 800},
+		{"bold", WeightBold},
+		{"black", 900},
+		{"heavy", 900},
+	}
+	flat := strings.Replace(strings.Replace(sub, " ", "", -1), "-", "", -1)
+	for _, w := range weights {
+		if strings.Contains(flat, w.name) {
+			weight = w.weight
+			break
+		}
+	}
+	return
+}
+
+// Add makes font available as the given face. It is registered with
+// draw2d, so that the face's Data can be drawn with.
+func (fm *FontManager) Add(family string, weight int, italic bool, font *truetype.Font) (face *FontFace) {
+	face = &FontFace{
+		Family: family,
+		Weight: weight,
+		Italic: italic,
+		Font:   font,
+	}
+
+	key := familyKey(family)
+	face.Data.Name = key
+	if weight != WeightNormal && weight != WeightBold {
+		face.Data.Name = fmt.Sprintf("%s-%d", key, weight)
+	}
+	switch {
+	case strings.Contains(key, "mono"):
+		face.Data.Family = draw2d.FontFamilyMono
+	case strings.Contains(key, "serif") && !strings.Contains(key, "sans"):
+		face.Data.Family = draw2d.FontFamilySerif
+	default:
+		face.Data.Family = draw2d.FontFamilySans
+	}
+	face.Data.Style = draw2d.FontStyleNormal
+	if weight >= 600 {
+		face.Data.Style |= draw2d.FontStyleBold
+	}
+	if italic {
+		face.Data.Style |= draw2d.FontStyleItalic
+	}
+
+	fm.guard.Lock()
+	defer fm.guard.Unlock()
+
+	if old, ok := fm.byData[face.Data]; ok {
+		for i, f := range fm.faces {
+			if f == old {
+				fm.faces = append(fm.faces[:i], fm.faces[i+1:]...)
+				break
+			}
+		}
+	}
+	fm.faces = append(fm.faces, face)
+	fm.byData[face.Data] = face
+	draw2d.RegisterFont(face.Data, font)
+	return
+}
+
+// Parse adds the font in ttf, taking its family, weight and slant from the
+// font's own names.
+func (fm *FontManager) Parse(ttf []byte) (face *FontFace, err error) {
+	font, err := truetype.Parse(ttf)
+	if err != nil {
+		return
+	}
+	family := font.Name(truetype.NameIDFontFamily)
+	if family == "" {
+		err = fmt.Errorf("font has no family name")
+		return
+	}
+	weight, italic := parseSubfamily(font.Name(truetype.NameIDFontSubfamily))
+	face = fm.Add(family, weight, italic, font)
+	return
+}
+
+// LoadFile adds the TrueType font in the file at path.
+func (fm *FontManager) LoadFile(path string) (face *FontFace, err error) {
+	ttf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	face, err = fm.Parse(ttf)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+		return
+	}
+	face.Path = path
+	return
+}
+
+// A FontErrors lists the fonts that could not be loaded.
+type FontErrors []error
+
+func (fe FontErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// LoadDir adds every .ttf file in the directory at dir and those below it.
+// Fonts that fail to load do not stop the others; they are reported
+// together as a FontErrors.
+func (fm *FontManager) LoadDir(dir string) (faces []*FontFace, err error) {
+	var errs FontErrors
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		if info.IsDir() || strings.ToLower(filepath.Ext(path)) != ".ttf" {
+			return nil
+		}
+		face, err := fm.LoadFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			return nil
+		}
+		faces = append(faces, face)
+		return nil
+	})
+	if walkErr != nil {
+		errs = append(errs, walkErr)
+	}
+	if len(errs) != 0 {
+		err = errs
+	}
+	return
+}
+
+// Families lists the names of the loaded families.
+func (fm *FontManager) Families() (families []string) {
+	fm.guard.RLock()
+	defer fm.guard.RUnlock()
+	seen := map[string]bool{}
+	for _, face := range fm.faces {
+		if !seen[face.Family] {
+			seen[face.Family] = true
+			families = append(families, face.Family)
+		}
+	}
+	return
+}
+
+// Resolve finds the face of family that best matches weight and italic:
+// the right slant if there is one, and then the nearest weight. It is nil
+// if the family is not loaded.
+func (fm *FontManager) Resolve(family string, weight int, italic bool) (face *FontFace) {
+	fm.guard.RLock()
+	defer fm.guard.RUnlock()
+	return fm.resolve(familyKey(family), weight, italic)
+}
+
+func (fm *FontManager) resolve(key string, weight int, italic bool) (face *FontFace) {
+	best := -1
+	for _, f := range fm.faces {
+		if familyKey(f.Family) != key {
+			continue
+		}
+		score := f.Weight - weight
+		if score < 0 {
+			score = -score
+		}
+		if f.Italic != italic {
+			score += 1000
+		}
+		if best == -1 || score < best {
+			face, best = f, score
+		}
+	}
+	return
+}
+
+// Face returns the face that draw2d knows as fd, or nil.
+func (fm *FontManager) Face(fd draw2d.FontData) (face *FontFace) {
+	fm.guard.RLock()
+	defer fm.guard.RUnlock()
+	face = fm.byData[fd]
+	return
+}
+
+// SetFallbacks gives the families to try, in order, for glyphs that faces
+// of family lack. An empty family sets the fallbacks tried for every
+// family, after its own.
+func (fm *FontManager) SetFallbacks(family string, fallbacks ...string) {
+	fm.guard.Lock()
+	defer fm.guard.Unlock()
+	if family == "" {
+		fm.defaultFallbacks = fallbacks
+		return
+	}
+	fm.fallbacks[familyKey(family)] = fallbacks
+}
+
+// FaceForRune finds the face to draw r with when asking for fd: fd's own
+// face if it has the glyph, or else the first fallback, in the same weight
+// and slant as near as possible, that does. If none do, it is fd's face.
+func (fm *FontManager) FaceForRune(fd draw2d.FontData, r rune) (face *FontFace) {
+	fm.guard.RLock()
+	defer fm.guard.RUnlock()
+
+	face = fm.byData[fd]
+	if face == nil || face.HasGlyph(r) {
+		return
+	}
+	key := familyKey(face.Family)
+	chain := append(append([]string{}, fm.fallbacks[key]...), fm.defaultFallbacks...)
+	for _, family := range chain {
+		fb := fm.resolve(familyKey(family), face.Weight, face.Italic)
+		if fb != nil && fb.HasGlyph(r) {
+			face = fb
+			return
+		}
+	}
+	return
+}
+
+// A FontRun is a piece of text that can be drawn with a single font.
+type FontRun struct {
+	Text string
+	Data draw2d.FontData
+}
+
+// Runs splits text into pieces that can each be drawn with one font,
+// substituting fallbacks for glyphs that fd lacks.
+func (fm *FontManager) Runs(text string, fd draw2d.FontData) (runs []FontRun) {
+	var cur draw2d.FontData
+	start := 0
+	for i, r := range text {
+		rfd := fd
+		if face := fm.FaceForRune(fd, r); face != nil {
+			rfd = face.Data
+		}
+		if i != 0 && rfd != cur {
+			runs = append(runs, FontRun{text[start:i], cur})
+			start = i
+		}
+		cur = rfd
+	}
+	if len(text) != 0 {
+		runs = append(runs, FontRun{text[start:], cur})
+	}
+	return
+}
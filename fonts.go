@@ -29,48 +29,29 @@ var DefaultFontData = draw2d.FontData{
 	Style:  draw2d.FontStyleNormal,
 }
 
+// GetFontHeight returns the distance from the top of the tallest glyph of
+// fd to the bottom of the deepest, at size.
 func GetFontHeight(fd draw2d.FontData, size float64) (height float64) {
-	font := draw2d.GetFont(fd)
-	fupe := font.FUnitsPerEm()
-	bounds := font.Bounds(fupe)
-	height = float64(bounds.YMax-bounds.YMin) * size / float64(fupe)
+	height = MeasureFont(fd, size).LineHeight
 	return
 }
 
+// RenderString draws text onto a buffer just large enough for it. Lines
+// break only at newlines, and the first baseline is the font's ascent from
+// the top.
 func RenderString(text string, fd draw2d.FontData, size float64, color color.Color) (buffer image.Image) {
-
-	const stretchFactor = 1.2
-
-	height := GetFontHeight(fd, size) * stretchFactor
-	widthMax := float64(len(text)) * size
-
-	buf := image.NewRGBA(image.Rectangle{
-		Min: image.Point{0, 0},
-		Max: image.Point{int(widthMax + 1), int(height + 1)},
-	})
-
-	gc := draw2d.NewGraphicContext(buf)
-	gc.Translate(0, height/stretchFactor)
-	gc.SetFontData(fd)
-	gc.SetFontSize(size)
-	gc.SetStrokeColor(color)
-	width := gc.FillString(text)
-
-	buffer = buf.SubImage(image.Rectangle{
-		Min: image.Point{0, 0},
-		Max: image.Point{int(width + 1), int(height + 1)},
-	})
-
+	buffer = LayoutText(Paragraph{
+		Text: text,
+		Font: fd,
+		Size: size,
+	}).Render(color)
 	return
 }
 
 func init() {
 	font, err := truetype.Parse(luxisr_ttf())
 	if err != nil {
-		// TODO: log error
-		println("error!")
-		println(err.Error())
+		panic("uik: embedded font: " + err.Error())
 	}
-
-	draw2d.RegisterFont(DefaultFontData, font)
+	Fonts.Add(DefaultFontData.Name, WeightNormal, false, font)
 }
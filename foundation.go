@@ -22,6 +22,7 @@ import (
 	"github.com/skelterjohn/go.wde"
 	"image"
 	"image/draw"
+	"math"
 	"sync"
 )
 
@@ -42,8 +43,8 @@ type Foundation struct {
 
 	DrawOp draw.Op
 
-	boundsGuard    sync.RWMutex
-	childrenBounds map[*Block]geom.Rect
+	placementGuard     sync.RWMutex
+	childrenPlacements map[*Block]Placement
 
 	Children      map[*Block]bool
 	ChildrenHints map[*Block]SizeHint
@@ -63,7 +64,7 @@ func (f *Foundation) Initialize() {
 	f.DrawOp = draw.Over
 	f.BlockSizeHints = make(chan BlockSizeHint, 1)
 	f.Children = map[*Block]bool{}
-	f.childrenBounds = map[*Block]geom.Rect{}
+	f.childrenPlacements = map[*Block]Placement{}
 	f.ChildrenHints = map[*Block]SizeHint{}
 	f.BlockInvalidations = make(chan BlockInvalidation, 1)
 	f.DragOriginBlocks = map[wde.Button][]*Block{}
@@ -76,9 +77,11 @@ func (f *Foundation) RemoveBlock(b *Block) {
 		return
 	}
 	delete(f.Children, b)
-	f.remChildBounds(b)
+	f.remChildPlacement(b)
 	delete(f.ChildrenHints, b)
 	b.Parent = nil
+	Accessibility.unlink(b)
+	BlockBuffers.release(b)
 }
 
 func (f *Foundation) AddBlock(b *Block) {
@@ -94,6 +97,7 @@ func (f *Foundation) AddBlock(b *Block) {
 
 	f.Children[b] = true
 	b.Parent = f
+	Accessibility.link(b, f)
 	// Report("invalidation link", b.ID, "->", f.ID)
 	b.Invalidations = make(InvalidationChan, 1)
 	go func(b *Block, blockInvalidator chan Invalidation) {
@@ -119,52 +123,139 @@ func (f *Foundation) AddBlock(b *Block) {
 		Foundation: f,
 		SizeHints:  sizeHints,
 	})
+
+	b.SendEvent(ScaleEvent{
+		Scale: f.Scale,
+	})
+	b.SendEvent(ThemeEvent{
+		Theme:     f.Theme,
+		Inherited: true,
+	})
 }
 
-func (f *Foundation) setChildBounds(b *Block, bounds geom.Rect) {
-	f.boundsGuard.Lock()
-	defer f.boundsGuard.Unlock()
+// updateChildPlacement applies update to b's placement. If b has not been
+// placed, and create is true, it starts as fully opaque and untransformed.
+// It returns the old and new placements.
+func (f *Foundation) updateChildPlacement(b *Block, create bool, update func(*Placement)) (old, pl Placement, ok bool) {
+	f.placementGuard.Lock()
+	defer f.placementGuard.Unlock()
 
-	f.childrenBounds[b] = bounds
+	old, ok = f.childrenPlacements[b]
+	if !ok && !create {
+		return
+	}
+	pl = old
+	if !ok {
+		pl.Opacity = 1
+	}
+	update(&pl)
+	f.childrenPlacements[b] = pl
+	return
 }
-func (f *Foundation) getChildBounds(b *Block) (bounds geom.Rect) {
-	f.boundsGuard.RLock()
-	defer f.boundsGuard.RUnlock()
+func (f *Foundation) getChildPlacement(b *Block) (pl Placement) {
+	f.placementGuard.RLock()
+	defer f.placementGuard.RUnlock()
 
-	bounds = f.childrenBounds[b]
+	pl = f.childrenPlacements[b]
 	return
 }
-func (f *Foundation) remChildBounds(b *Block) {
-	f.boundsGuard.Lock()
-	defer f.boundsGuard.Unlock()
+func (f *Foundation) remChildPlacement(b *Block) {
+	f.placementGuard.Lock()
+	defer f.placementGuard.Unlock()
 
-	delete(f.childrenBounds, b)
+	delete(f.childrenPlacements, b)
 }
-func (f *Foundation) getChildBoundsMap() (cbounds map[*Block]geom.Rect) {
-	f.boundsGuard.RLock()
-	defer f.boundsGuard.RUnlock()
+func (f *Foundation) getChildPlacementMap() (cplacements map[*Block]Placement) {
+	f.placementGuard.RLock()
+	defer f.placementGuard.RUnlock()
 
-	cbounds = make(map[*Block]geom.Rect)
-	for c, b := range f.childrenBounds {
-		cbounds[c] = b
+	cplacements = make(map[*Block]Placement)
+	for c, pl := range f.childrenPlacements {
+		cplacements[c] = pl
 	}
 
 	return
 }
 
+// PlaceBlock adds b, if needed, and gives it the provided bounds, snapped
+// to physical pixels. Any opacity or transform it already had is kept.
 func (f *Foundation) PlaceBlock(b *Block, bounds geom.Rect) {
 	// Report(f.ID, "placing", b.ID)
 	f.AddBlock(b)
-	f.setChildBounds(b, bounds)
+	bounds = SnapRect(bounds, f.Scale)
+	f.updateChildPlacement(b, true, func(pl *Placement) {
+		pl.Bounds = bounds
+	})
 	b.ResizeEvents.Stack(ResizeEvent{
 		Size: geom.Coord{bounds.Max.X - bounds.Min.X, bounds.Max.Y - bounds.Min.Y},
 	})
 }
 
+// SetBlockOpacity changes how opaque an already placed child is drawn, from
+// 0 (invisible) to 1. It is safe to call from any goroutine.
+func (f *Foundation) SetBlockOpacity(b *Block, opacity float64) {
+	old, pl, ok := f.updateChildPlacement(b, false, func(pl *Placement) {
+		pl.Opacity = math.Min(1, math.Max(0, opacity))
+	})
+	if !ok {
+		return
+	}
+	f.Invalidate(old.Frame(), pl.Frame())
+}
+
+// SetBlockTransform changes the transform an already placed child is drawn
+// and hit-tested through. A nil transform removes it. It is safe to call
+// from any goroutine.
+func (f *Foundation) SetBlockTransform(b *Block, t *Transform) {
+	if t != nil {
+		tcopy := *t
+		t = &tcopy
+	}
+	old, pl, ok := f.updateChildPlacement(b, false, func(pl *Placement) {
+		pl.Transform = t
+	})
+	if !ok {
+		return
+	}
+	f.Invalidate(old.Frame(), pl.Frame())
+}
+
+// SetBlockBlend changes how an already placed child's colors mix with what
+// is drawn beneath it. It is safe to call from any goroutine.
+func (f *Foundation) SetBlockBlend(b *Block, mode BlendMode) {
+	_, pl, ok := f.updateChildPlacement(b, false, func(pl *Placement) {
+		pl.Blend = mode
+	})
+	if !ok {
+		return
+	}
+	f.Invalidate(pl.Frame())
+}
+
+// SetBlockEffects replaces the effects applied to an already placed child
+// before it is composited. It is safe to call from any goroutine.
+func (f *Foundation) SetBlockEffects(b *Block, effects ...Effect) {
+	effects = append([]Effect(nil), effects...)
+	old, pl, ok := f.updateChildPlacement(b, false, func(pl *Placement) {
+		pl.Effects = effects
+	})
+	if !ok {
+		return
+	}
+
+	// the old effects' output is no good anymore
+	b.bufferGuard.Lock()
+	Buffers.Put(b.effectBuffer)
+	b.effectBuffer = nil
+	b.bufferGuard.Unlock()
+
+	f.Invalidate(old.Frame(), pl.Frame())
+}
+
 func (f *Foundation) BlocksForCoord(p geom.Coord) (bs []*Block) {
 	// quad-tree one day?
-	for c, b := range f.getChildBoundsMap() {
-		if b.ContainsCoord(p) {
+	for c, pl := range f.getChildPlacementMap() {
+		if pl.Contains(p) {
 			bs = append(bs, c)
 		}
 	}
@@ -173,8 +264,8 @@ func (f *Foundation) BlocksForCoord(p geom.Coord) (bs []*Block) {
 
 func (f *Foundation) InvokeOnBlocksUnder(p geom.Coord, foo func(*Block)) {
 	// quad-tree one day?
-	for c, b := range f.getChildBoundsMap() {
-		if b.ContainsCoord(p) {
+	for c, pl := range f.getChildPlacementMap() {
+		if pl.Contains(p) {
 			foo(c)
 			return
 		}
@@ -187,41 +278,64 @@ func (f *Foundation) InvokeOnBlocksUnder(p geom.Coord, foo func(*Block)) {
 
 func (f *Foundation) Draw(buffer draw.Image, invalidRects RectSet) {
 	gc := draw2d.NewGraphicContext(buffer)
+	gc.Scale(f.Scale, f.Scale)
 	f.DoPaint(gc)
-	for child, bounds := range f.getChildBoundsMap() {
-		r := RectangleForRect(bounds)
+	for child, pl := range f.getChildPlacementMap() {
+		frame := pl.Frame()
+
+		child.bufferGuard.Lock()
 
 		// only redraw those that have been invalidated or are
 		// otherwise unable to draw themselves
-		if child.buffer == nil || invalidRects.Intersects(bounds) {
-			subInv := invalidRects.Intersection(bounds).Translate(bounds.Min.Times(-1))
-			or := image.Rectangle{
-				Max: image.Point{int(child.Size.X), int(child.Size.Y)},
-			}
+		redrawn := false
+		if child.buffer == nil || invalidRects.Intersects(frame) {
+			redrawn = true
+			subInv := pl.RectsToLocal(invalidRects.Intersection(frame))
+			or := child.pixelBounds()
 			if child.buffer == nil || child.buffer.Bounds() != or {
-				child.buffer = image.NewRGBA(or)
+				Buffers.Put(child.buffer)
+				child.buffer = Buffers.Get(or.Dx(), or.Dy())
 			} else {
 				for _, r := range subInv {
-					ir := RectangleForRect(r)
-					ZeroRGBA(child.buffer.(*image.RGBA).SubImage(ir).(*image.RGBA))
+					ir := ScaledRectangleForRect(r, child.Scale)
+					ZeroRGBA(child.buffer.SubImage(ir).(*image.RGBA))
 				}
 			}
 			child.Drawer.Draw(child.buffer, subInv)
 		}
 
-		draw.Draw(buffer, r, child.buffer, image.Point{0, 0}, f.DrawOp)
+		src := child.buffer
+		if len(pl.Effects) != 0 {
+			if redrawn || child.effectBuffer == nil {
+				Buffers.Put(child.effectBuffer)
+				child.effectBuffer = applyEffects(child.buffer, pl.Effects, child.Scale)
+			}
+			src = child.effectBuffer
+		}
+		pl.composite(buffer, src, f.DrawOp, child.Scale, f.Scale)
+
+		BlockBuffers.touch(child)
+		child.bufferGuard.Unlock()
 	}
 }
 
 func (f *Foundation) DoBlockInvalidation(e BlockInvalidation) {
-	cbounds, ok := f.getChildBoundsMap()[e.Block]
+	pl, ok := f.getChildPlacementMap()[e.Block]
 	if !ok {
 		return
 	}
+	// a blur or shadow spreads what changed into the margin around it, by
+	// whole pixels, as applyEffects pads the buffer
+	m := effectsMargin(pl.Effects)
+	if scale := e.Block.Scale; m != 0 && scale > 0 {
+		m = math.Ceil(m*scale) / scale
+	}
 	for _, invBounds := range e.Bounds {
-		invBounds.Translate(cbounds.Min)
-		f.Invalidate(invBounds)
-
+		if m != 0 {
+			invBounds.Min = invBounds.Min.Minus(geom.Coord{m, m})
+			invBounds.Max = invBounds.Max.Plus(geom.Coord{m, m})
+		}
+		f.Invalidate(pl.RectFromLocal(invBounds))
 	}
 }
 
@@ -235,20 +349,20 @@ func (f *Foundation) KeyFocusRequest(e KeyFocusRequest) {
 		return
 	}
 	if e.Block != f.KeyFocus && f.KeyFocus != nil {
-		f.KeyFocus.UserEventsIn.SendOrDrop(KeyFocusEvent{
+		f.KeyFocus.SendEvent(KeyFocusEvent{
 			Focus: false,
 		})
 	}
 	f.KeyFocus = e.Block
 	if f.HasKeyFocus {
 		if f.KeyFocus != nil {
-			f.KeyFocus.UserEventsIn.SendOrDrop(KeyFocusEvent{
+			f.KeyFocus.SendEvent(KeyFocusEvent{
 				Focus: true,
 			})
 		}
 	} else {
 		if f.Parent != nil {
-			f.Parent.UserEventsIn.SendOrDrop(KeyFocusRequest{
+			f.Parent.SendEvent(KeyFocusRequest{
 				Block: &f.Block,
 			})
 		}
@@ -259,6 +373,12 @@ func (f *Foundation) HandleEvent(e interface{}) {
 	switch e := e.(type) {
 	case CloseEvent:
 		f.DoCloseEvent(e)
+	case ScaleEvent:
+		f.DoScaleEvent(e)
+	case ThemeEvent:
+		f.DoThemeEvent(e)
+	case LocaleEvent:
+		f.DoLocaleEvent(e)
 	case MouseDownEvent:
 		f.DoMouseDownEvent(e)
 	case MouseUpEvent:
@@ -299,8 +419,9 @@ func (f *Foundation) DoKeyFocusEvent(e KeyFocusEvent) {
 		return
 	}
 	f.HasKeyFocus = e.Focus
+	f.SetState(StateFocused, e.Focus)
 	if f.KeyFocus != nil {
-		f.KeyFocus.UserEventsIn.SendOrDrop(e)
+		f.KeyFocus.SendEvent(e)
 	}
 }
 
@@ -308,12 +429,12 @@ func (f *Foundation) DoKeyEvent(e interface{}) {
 	if f.KeyFocus == nil {
 		return
 	}
-	f.KeyFocus.UserEventsIn.SendOrDrop(e)
+	f.KeyFocus.SendEvent(e)
 }
 
 func (f *Foundation) DoMouseDownEvent(e MouseDownEvent) {
 	f.InvokeOnBlocksUnder(e.Loc, func(b *Block) {
-		bbs := f.getChildBounds(b)
+		bpl := f.getChildPlacement(b)
 		if b == nil {
 			return
 		}
@@ -321,8 +442,8 @@ func (f *Foundation) DoMouseDownEvent(e MouseDownEvent) {
 		// Report(f.ID, "mouse origin", b.ID)
 		ce := e
 
-		ce.Loc = e.Loc.Minus(bbs.Min)
-		b.UserEventsIn.SendOrDrop(ce)
+		ce.Loc = bpl.ToLocal(e.Loc)
+		b.SendEvent(ce)
 	})
 }
 
@@ -332,34 +453,34 @@ func (f *Foundation) DoMouseMovedEvent(e MouseMovedEvent) {
 		fromSet[b] = true
 	})
 	f.InvokeOnBlocksUnder(e.Loc, func(b *Block) {
-		bbs := f.getChildBounds(b)
+		bpl := f.getChildPlacement(b)
 		if !fromSet[b] {
 			ee := MouseEnteredEvent{
 				Event:        e.Event,
 				MouseLocator: e.MouseLocator,
 				From:         e.From,
 			}
-			ee.Loc = ee.Loc.Minus(bbs.Min)
-			ee.From = ee.From.Minus(bbs.Min)
-			b.UserEventsIn.SendOrDrop(ee)
+			ee.Loc = bpl.ToLocal(ee.Loc)
+			ee.From = bpl.ToLocal(ee.From)
+			b.SendEvent(ee)
 		} else {
 			delete(fromSet, b)
 		}
 		ce := e
-		ce.Loc = e.Loc.Minus(bbs.Min)
-		ce.From = e.From.Minus(bbs.Min)
-		b.UserEventsIn.SendOrDrop(ce)
+		ce.Loc = bpl.ToLocal(e.Loc)
+		ce.From = bpl.ToLocal(e.From)
+		b.SendEvent(ce)
 	})
 	for fromBlock := range fromSet {
-		bbs := f.getChildBounds(fromBlock)
+		bpl := f.getChildPlacement(fromBlock)
 		ee := MouseExitedEvent{
 			Event:        e.Event,
 			MouseLocator: e.MouseLocator,
 			From:         e.From,
 		}
-		ee.Loc = ee.Loc.Minus(bbs.Min)
-		ee.From = ee.From.Minus(bbs.Min)
-		fromBlock.UserEventsIn.SendOrDrop(ee)
+		ee.Loc = bpl.ToLocal(ee.Loc)
+		ee.From = bpl.ToLocal(ee.From)
+		fromBlock.SendEvent(ee)
 	}
 }
 
@@ -367,11 +488,11 @@ func (f *Foundation) DoMouseUpEvent(e MouseUpEvent) {
 	touched := map[*Block]bool{}
 	f.InvokeOnBlocksUnder(e.Loc, func(b *Block) {
 		touched[b] = true
-		bbs := f.getChildBounds(b)
+		bpl := f.getChildPlacement(b)
 		if b != nil {
 			be := e
-			be.Loc = be.Loc.Minus(bbs.Min)
-			b.UserEventsIn.SendOrDrop(be)
+			be.Loc = bpl.ToLocal(be.Loc)
+			b.SendEvent(be)
 		}
 	})
 	if origins, ok := f.DragOriginBlocks[e.Which]; ok {
@@ -380,9 +501,9 @@ func (f *Foundation) DoMouseUpEvent(e MouseUpEvent) {
 				continue
 			}
 			oe := e
-			obbs := f.getChildBounds(origin)
-			oe.Loc = oe.Loc.Minus(obbs.Min)
-			origin.UserEventsIn.SendOrDrop(oe)
+			opl := f.getChildPlacement(origin)
+			oe.Loc = opl.ToLocal(oe.Loc)
+			origin.SendEvent(oe)
 		}
 	}
 	delete(f.DragOriginBlocks, e.Which)
@@ -397,37 +518,37 @@ func (f *Foundation) DoMouseDraggedEvent(e MouseDraggedEvent) {
 	touched := map[*Block]bool{}
 	f.InvokeOnBlocksUnder(e.Loc, func(b *Block) {
 		touched[b] = true
-		bbs := f.getChildBounds(b)
+		bpl := f.getChildPlacement(b)
 		if !fromSet[b] {
 			ee := MouseEnteredEvent{
 				Event:        e.Event,
 				MouseLocator: e.MouseLocator,
 				From:         e.From,
 			}
-			ee.Loc = ee.Loc.Minus(bbs.Min)
-			ee.From = ee.From.Minus(bbs.Min)
-			b.UserEventsIn.SendOrDrop(ee)
+			ee.Loc = bpl.ToLocal(ee.Loc)
+			ee.From = bpl.ToLocal(ee.From)
+			b.SendEvent(ee)
 		} else {
 			delete(fromSet, b)
 		}
 		if b != nil {
 			be := e
-			be.Loc = be.Loc.Minus(bbs.Min)
-			be.From = be.From.Minus(bbs.Min)
+			be.Loc = bpl.ToLocal(be.Loc)
+			be.From = bpl.ToLocal(be.From)
 			// Report(f.ID, "forward", b.ID)
-			b.UserEventsIn.SendOrDrop(be)
+			b.SendEvent(be)
 		}
 	})
 	for fromBlock := range fromSet {
-		bbs := f.getChildBounds(fromBlock)
+		bpl := f.getChildPlacement(fromBlock)
 		ee := MouseExitedEvent{
 			Event:        e.Event,
 			MouseLocator: e.MouseLocator,
 			From:         e.From,
 		}
-		ee.Loc = ee.Loc.Minus(bbs.Min)
-		ee.From = ee.From.Minus(bbs.Min)
-		fromBlock.UserEventsIn.SendOrDrop(ee)
+		ee.Loc = bpl.ToLocal(ee.Loc)
+		ee.From = bpl.ToLocal(ee.From)
+		fromBlock.SendEvent(ee)
 	}
 	if origins, ok := f.DragOriginBlocks[e.Which]; ok {
 		for _, origin := range origins {
@@ -437,16 +558,48 @@ func (f *Foundation) DoMouseDraggedEvent(e MouseDraggedEvent) {
 			}
 			// Report(f.ID, "origin forward", origin.ID)
 			oe := e
-			obbs := f.getChildBounds(origin)
-			oe.Loc = oe.Loc.Minus(obbs.Min)
-			oe.From = oe.From.Minus(obbs.Min)
-			origin.UserEventsIn.SendOrDrop(oe)
+			opl := f.getChildPlacement(origin)
+			oe.Loc = opl.ToLocal(oe.Loc)
+			oe.From = opl.ToLocal(oe.From)
+			origin.SendEvent(oe)
 		}
 	}
 }
 
+func (f *Foundation) DoScaleEvent(e ScaleEvent) {
+	if e.Scale == f.Scale {
+		return
+	}
+	f.Scale = e.Scale
+	for b := range f.Children {
+		b.SendEvent(e)
+	}
+	f.Invalidate()
+}
+
+// DoThemeEvent changes the foundation's theme, and passes it on to the
+// children.
+func (f *Foundation) DoThemeEvent(e ThemeEvent) {
+	if !f.applyTheme(e) {
+		return
+	}
+	for b := range f.Children {
+		b.SendEvent(ThemeEvent{
+			Theme:     f.Theme,
+			Inherited: true,
+		})
+	}
+}
+
+// DoLocaleEvent passes a change of locale on to the children.
+func (f *Foundation) DoLocaleEvent(e LocaleEvent) {
+	for b := range f.Children {
+		b.SendEvent(e)
+	}
+}
+
 func (f *Foundation) DoCloseEvent(e CloseEvent) {
 	for b := range f.Children {
-		b.UserEventsIn.SendOrDrop(e)
+		b.SendEvent(e)
 	}
 }
@@ -19,6 +19,7 @@ package uik
 import (
 	"github.com/skelterjohn/geom"
 	"image"
+	"math"
 )
 
 // type Coord struct {
@@ -41,6 +42,27 @@ func RectangleForRect(b geom.Rect) (r image.Rectangle) {
 	return
 }
 
+// ScaledRectangleForRect returns the pixels touched by b, in a buffer with
+// scale pixels per unit.
+func ScaledRectangleForRect(b geom.Rect, scale float64) (r image.Rectangle) {
+	r.Min.X = int(math.Floor(b.Min.X * scale))
+	r.Max.X = int(math.Ceil(b.Max.X * scale))
+	r.Min.Y = int(math.Floor(b.Min.Y * scale))
+	r.Max.Y = int(math.Ceil(b.Max.Y * scale))
+	return
+}
+
+// SnapRect moves the edges of b to the nearest pixel boundary, in a buffer
+// with scale pixels per unit.
+func SnapRect(b geom.Rect, scale float64) geom.Rect {
+	snap := func(x float64) float64 {
+		return math.Floor(x*scale+0.5) / scale
+	}
+	b.Min.X, b.Min.Y = snap(b.Min.X), snap(b.Min.Y)
+	b.Max.X, b.Max.Y = snap(b.Max.X), snap(b.Max.Y)
+	return b
+}
+
 type RectSet []geom.Rect
 
 func (rs RectSet) Translate(offset geom.Coord) (nrs RectSet) {
@@ -0,0 +1,278 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"code.google.com/p/freetype-go/freetype/truetype"
+	"container/list"
+	"github.com/skelterjohn/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// GlyphSubpixels is the number of horizontal positions, within a pixel,
+// that glyphs are rasterised at.
+const GlyphSubpixels = 4
+
+// A Glyph is the coverage of a single rasterised glyph. The bounds of Mask
+// are relative to the pen position, on the baseline.
+type Glyph struct {
+	Mask *image.Alpha
+}
+
+type glyphKey struct {
+	data     draw2d.FontData
+	size     float64
+	index    truetype.Index
+	subpixel int
+	synth    synthesis
+}
+
+type GlyphStats struct {
+	Hits, Misses int
+	// Bytes held by cached masks, the budget for them, and how many
+	// glyphs have been evicted to stay within it.
+	Bytes, Budget int
+	Evictions     int
+}
+
+// A GlyphCache keeps rasterised glyphs as alpha masks, so that they can be
+// drawn in any color by compositing. The least recently used are dropped
+// once their total size goes over budget. It is safe for use from
+// multiple goroutines.
+type GlyphCache struct {
+	mu     sync.Mutex
+	lru    *list.List
+	elems  map[glyphKey]*list.Element
+	budget int
+	stats  GlyphStats
+}
+
+type glyphEntry struct {
+	key   glyphKey
+	glyph *Glyph
+}
+
+func NewGlyphCache(budget int) (c *GlyphCache) {
+	c = new(GlyphCache)
+	c.lru = list.New()
+	c.elems = map[glyphKey]*list.Element{}
+	c.budget = budget
+	return
+}
+
+// SetBudget changes the number of bytes cached masks may occupy. A budget
+// of zero or less means no limit.
+func (c *GlyphCache) SetBudget(budget int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+	c.evict()
+}
+
+func (c *GlyphCache) Stats() (stats GlyphStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats = c.stats
+	stats.Budget = c.budget
+	return
+}
+
+// Glyph returns the glyph for r, as drawn by the font fd at size, with the
+// pen frac of a pixel to the right of a pixel boundary.
+func (c *GlyphCache) Glyph(fd draw2d.FontData, size float64, r rune, frac float64) (g *Glyph) {
+	return c.glyph(fd, size, r, frac, 0)
+}
+
+// glyph is Glyph, thickened or slanted as synth says.
+func (c *GlyphCache) glyph(fd draw2d.FontData, size float64, r rune, frac float64, synth synthesis) (g *Glyph) {
+	font, rfd := fontFor(fd, r)
+	if font == nil {
+		return
+	}
+	sub := int(frac*GlyphSubpixels) % GlyphSubpixels
+	key := glyphKey{rfd, size, font.Index(r), sub, synth}
+
+	c.mu.Lock()
+	if el, ok := c.elems[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		g = el.Value.(*glyphEntry).glyph
+		c.mu.Unlock()
+		return
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// rasterise without holding the lock; if another goroutine does the
+	// same glyph at the same time, the later one wins
+	g = rasteriseGlyph(rfd, size, r, float64(sub)/GlyphSubpixels)
+	if synth&synthBold != 0 {
+		g.Mask = embolden(g.Mask, int(math.Max(1, math.Floor(size*FontDPI/72/24+0.5))))
+	}
+	if synth&synthItalic != 0 {
+		g.Mask = slant(g.Mask, ItalicSlant)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.elems[key]; ok {
+		c.stats.Bytes -= len(el.Value.(*glyphEntry).glyph.Mask.Pix)
+		c.lru.Remove(el)
+	}
+	c.elems[key] = c.lru.PushFront(&glyphEntry{key, g})
+	c.stats.Bytes += len(g.Mask.Pix)
+	c.evict()
+	return
+}
+
+func (c *GlyphCache) evict() {
+	if c.budget <= 0 {
+		return
+	}
+	for c.stats.Bytes > c.budget && c.lru.Len() > 1 {
+		el := c.lru.Back()
+		ge := el.Value.(*glyphEntry)
+		c.lru.Remove(el)
+		delete(c.elems, ge.key)
+		c.stats.Bytes -= len(ge.glyph.Mask.Pix)
+		c.stats.Evictions++
+	}
+}
+
+// rasteriseGlyph draws r through draw2d, and keeps only the coverage.
+func rasteriseGlyph(fd draw2d.FontData, size float64, r rune, frac float64) (g *Glyph) {
+	g = new(Glyph)
+	font := draw2d.GetFont(fd)
+	fupe := font.FUnitsPerEm()
+	bounds := font.Bounds(fupe)
+	em := size * FontDPI / 72
+	toPixels := func(x int32) int {
+		return int(math.Ceil(math.Abs(float64(x)) * em / float64(fupe)))
+	}
+	// room for the largest glyph in the font, with a pixel to spare all
+	// round for antialiasing
+	left, right := toPixels(bounds.XMin)+1, toPixels(bounds.XMax)+2
+	up, down := toPixels(bounds.YMax)+1, toPixels(bounds.YMin)+1
+
+	buf := Buffers.Get(left+right, up+down)
+	defer Buffers.Put(buf)
+
+	gc := draw2d.NewGraphicContext(buf)
+	gc.Translate(float64(left)+frac, float64(up))
+	gc.SetFontData(fd)
+	gc.SetFontSize(size)
+	gc.SetStrokeColor(color.White)
+	gc.FillString(string(r))
+
+	// crop to what was drawn
+	bb := buf.Bounds()
+	used := image.Rectangle{}
+	for y := bb.Min.Y; y < bb.Max.Y; y++ {
+		for x := bb.Min.X; x < bb.Max.X; x++ {
+			if buf.Pix[buf.PixOffset(x, y)+3] != 0 {
+				used = used.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	g.Mask = image.NewAlpha(used.Sub(image.Point{left, up}))
+	draw.Draw(g.Mask, g.Mask.Bounds(), buf, used.Min, draw.Src)
+	return
+}
+
+// ItalicSlant is how far, across per pixel up, glyphs lean when italic is
+// synthesised for a family without an italic face.
+const ItalicSlant = 0.2
+
+// embolden thickens the coverage in m by w pixels to the right, as though
+// the glyph were drawn w+1 times, a pixel apart.
+func embolden(m *image.Alpha, w int) (bold *image.Alpha) {
+	r := m.Rect
+	r.Max.X += w
+	bold = image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var a uint8
+			for k := 0; k <= w; k++ {
+				if c := m.AlphaAt(x-k, y).A; c > a {
+					a = c
+				}
+			}
+			bold.SetAlpha(x, y, color.Alpha{a})
+		}
+	}
+	return
+}
+
+// slant shears the coverage in m, whose origin is on the baseline, so that
+// each row moves right by slope for every pixel it is above the baseline.
+func slant(m *image.Alpha, slope float64) (slanted *image.Alpha) {
+	if m.Rect.Empty() {
+		return m
+	}
+	shift := func(y int) float64 {
+		return -(float64(y) + 0.5) * slope
+	}
+	r := m.Rect
+	r.Min.X += int(math.Floor(shift(m.Rect.Max.Y - 1)))
+	r.Max.X += int(math.Ceil(shift(m.Rect.Min.Y)))
+	slanted = image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		s := shift(y)
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// blend the two source pixels that x falls between
+			sx := float64(x) - s
+			x0 := int(math.Floor(sx))
+			f := sx - float64(x0)
+			a := (1-f)*float64(m.AlphaAt(x0, y).A) + f*float64(m.AlphaAt(x0+1, y).A)
+			slanted.SetAlpha(x, y, color.Alpha{uint8(a + 0.5)})
+		}
+	}
+	return
+}
+
+// Glyphs caches the glyphs that DrawString draws. By default it may use up
+// to 4MB.
+var Glyphs = NewGlyphCache(4 << 20)
+
+// DrawString draws text onto dst in color c, from cached glyphs, with the
+// start of its baseline at pen, in dst's pixels.
+func DrawString(dst draw.Image, pen geom.Coord, text string, fd draw2d.FontData, size float64, c color.Color) {
+	tm := MeasureString(text, fd, size)
+	drawRunes(dst, pen, []rune(text), tm.Carets, fd, size, 0, c)
+}
+
+// drawRunes draws each rune with its pen position at carets[i] along the
+// baseline from pen, with the glyphs altered as synth says.
+func drawRunes(dst draw.Image, pen geom.Coord, runes []rune, carets []float64, fd draw2d.FontData, size float64, synth synthesis, c color.Color) {
+	src := image.NewUniform(c)
+	y := int(math.Floor(pen.Y + 0.5))
+	for i, r := range runes {
+		x := pen.X + carets[i]
+		px := math.Floor(x)
+		g := Glyphs.glyph(fd, size, r, x-px, synth)
+		if g == nil || g.Mask.Rect.Empty() {
+			continue
+		}
+		dr := g.Mask.Rect.Add(image.Point{int(px), y})
+		draw.DrawMask(dst, dr, src, image.Point{}, g.Mask, g.Mask.Rect.Min, draw.Over)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"image"
+	"testing"
+)
+
+// stem is a mask one pixel wide, from 4 pixels above the baseline to 2
+// below.
+func stem() (m *image.Alpha) {
+	m = image.NewAlpha(image.Rect(0, -4, 1, 2))
+	for i := range m.Pix {
+		m.Pix[i] = 0xff
+	}
+	return
+}
+
+func TestEmbolden(t *testing.T) {
+	for _, w := range []int{1, 2} {
+		b := embolden(stem(), w)
+		if got, want := b.Rect, image.Rect(0, -4, 1+w, 2); got != want {
+			t.Errorf("embolden by %d: bounds %v, want %v", w, got, want)
+		}
+		for x := 0; x <= w; x++ {
+			if a := b.AlphaAt(x, 0).A; a != 0xff {
+				t.Errorf("embolden by %d: alpha at %d is %d, want 255", w, x, a)
+			}
+		}
+	}
+}
+
+func TestSlant(t *testing.T) {
+	s := slant(stem(), 1)
+	tests := []struct {
+		y, x int
+	}{
+		// rows lean right above the baseline, and left below it
+		{-4, 3},
+		{-1, 0},
+		{1, -2},
+	}
+	for _, test := range tests {
+		// the row's center is half a pixel down, so coverage is split
+		// evenly between x and x+1
+		if a := s.AlphaAt(test.x, test.y).A; a < 0x70 || a > 0x90 {
+			t.Errorf("row %d: alpha at %d is %d, want about half", test.y, test.x, a)
+		}
+	}
+	if a := s.AlphaAt(0, -4).A; a != 0 {
+		t.Errorf("row -4 still covers its old pixel, with alpha %d", a)
+	}
+	if m := slant(image.NewAlpha(image.Rectangle{}), 1); !m.Rect.Empty() {
+		t.Errorf("slanting nothing gave %v", m.Rect)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"unicode"
+)
+
+// Grapheme clusters are what a reader thinks of as single characters, such
+// as a letter with combining accents, a flag, or an emoji sequence joined
+// with zero-width joiners. Text editing should never split them.
+//
+// The rules here follow the extended grapheme clusters of Unicode Standard
+// Annex #29, with the character properties approximated from the unicode
+// package's categories.
+
+type graphemeClass int
+
+const (
+	gcOther graphemeClass = iota
+	gcCR
+	gcLF
+	gcControl
+	gcExtend
+	gcZWJ
+	gcRegionalIndicator
+	gcSpacingMark
+	gcL
+	gcV
+	gcT
+	gcLV
+	gcLVT
+	gcPictographic
+)
+
+func graphemeClassOf(r rune) graphemeClass {
+	switch {
+	case r == '\r':
+		return gcCR
+	case r == '\n':
+		return gcLF
+	case r == 0x200D:
+		return gcZWJ
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return gcRegionalIndicator
+	case r >= 0x1F3FB && r <= 0x1F3FF, // emoji skin tones
+		r >= 0xFE00 && r <= 0xFE0F, // variation selectors
+		r >= 0xE0100 && r <= 0xE01EF,
+		r >= 0xE0020 && r <= 0xE007F, // tags
+		r == 0x200C,
+		unicode.In(r, unicode.Mn, unicode.Me):
+		return gcExtend
+	case unicode.Is(unicode.Mc, r):
+		return gcSpacingMark
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return gcL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return gcV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return gcT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return gcLV
+		}
+		return gcLVT
+	case unicode.In(r, unicode.Cc, unicode.Zl, unicode.Zp),
+		unicode.Is(unicode.Cf, r) && r != 0x200C && r != 0x200D:
+		return gcControl
+	case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF,
+		r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122,
+		r >= 0x2190 && r <= 0x21FF, r >= 0x2B00 && r <= 0x2BFF:
+		return gcPictographic
+	}
+	return gcOther
+}
+
+// NextGrapheme returns the index in runes of the start of the grapheme
+// cluster after the one starting at i, or len(runes).
+func NextGrapheme(runes []rune, i int) int {
+	if i >= len(runes) {
+		return len(runes)
+	}
+	prev := graphemeClassOf(runes[i])
+	// whether a pictograph, then any extenders, came before a ZWJ
+	pictoRun := prev == gcPictographic
+	// the number of regional indicators in a row so far
+	riCount := 0
+	if prev == gcRegionalIndicator {
+		riCount = 1
+	}
+	for j := i + 1; j < len(runes); j++ {
+		cur := graphemeClassOf(runes[j])
+		if graphemeBreak(prev, cur, pictoRun, riCount) {
+			return j
+		}
+		switch {
+		case cur == gcPictographic:
+			pictoRun = true
+		case cur == gcExtend || cur == gcZWJ:
+		default:
+			pictoRun = false
+		}
+		if cur == gcRegionalIndicator {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		prev = cur
+	}
+	return len(runes)
+}
+
+// graphemeBreak decides whether there is a boundary between a rune of
+// class prev and one of class cur.
+func graphemeBreak(prev, cur graphemeClass, pictoRun bool, riCount int) bool {
+	switch {
+	case prev == gcCR && cur == gcLF:
+		return false
+	case prev == gcCR || prev == gcLF || prev == gcControl:
+		return true
+	case cur == gcCR || cur == gcLF || cur == gcControl:
+		return true
+	case prev == gcL && (cur == gcL || cur == gcV || cur == gcLV || cur == gcLVT):
+		return false
+	case (prev == gcLV || prev == gcV) && (cur == gcV || cur == gcT):
+		return false
+	case (prev == gcLVT || prev == gcT) && cur == gcT:
+		return false
+	case cur == gcExtend || cur == gcZWJ || cur == gcSpacingMark:
+		return false
+	case prev == gcZWJ && cur == gcPictographic && pictoRun:
+		return false
+	case prev == gcRegionalIndicator && cur == gcRegionalIndicator:
+		// flags are pairs of indicators
+		return riCount%2 == 0
+	}
+	return true
+}
+
+// GraphemeBoundaries returns the index of the start of every grapheme
+// cluster in runes, followed by len(runes).
+func GraphemeBoundaries(runes []rune) (bounds []int) {
+	for i := 0; i < len(runes); i = NextGrapheme(runes, i) {
+		bounds = append(bounds, i)
+	}
+	bounds = append(bounds, len(runes))
+	return
+}
+
+// PrevGrapheme returns the index in runes of the start of the grapheme
+// cluster that ends at i, or 0.
+func PrevGrapheme(runes []rune, i int) (prev int) {
+	for _, b := range GraphemeBoundaries(runes) {
+		if b >= i {
+			break
+		}
+		prev = b
+	}
+	return
+}
+
+// SnapToGrapheme returns the start of the grapheme cluster containing the
+// rune at i.
+func SnapToGrapheme(runes []rune, i int) int {
+	if i >= len(runes) {
+		return len(runes)
+	}
+	return PrevGrapheme(runes, i+1)
+}
+
+// Words, for moving and selecting by word, are runs of letters, digits and
+// marks. This is simpler than the word boundaries of Annex #29, but agrees
+// with them for most text.
+
+func isWordRune(r rune) bool {
+	return unicode.In(r, unicode.L, unicode.N, unicode.M) || r == '_'
+}
+
+// NextWord returns the index in runes of the end of the word at or after i,
+// or len(runes).
+func NextWord(runes []rune, i int) int {
+	for i < len(runes) && !isWordRune(runes[i]) {
+		i++
+	}
+	for i < len(runes) && isWordRune(runes[i]) {
+		i++
+	}
+	return i
+}
+
+// PrevWord returns the index in runes of the start of the word before i, or
+// 0.
+func PrevWord(runes []rune, i int) int {
+	for i > 0 && !isWordRune(runes[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(runes[i-1]) {
+		i--
+	}
+	return i
+}
+
+// WordAt returns the bounds of the word around the rune at i, or of the run
+// of spaces and punctuation if i isn't in a word.
+func WordAt(runes []rune, i int) (start, end int) {
+	if len(runes) == 0 {
+		return
+	}
+	if i >= len(runes) {
+		i = len(runes) - 1
+	}
+	word := isWordRune(runes[i])
+	start, end = i, i+1
+	for start > 0 && isWordRune(runes[start-1]) == word {
+		start--
+	}
+	for end < len(runes) && isWordRune(runes[end]) == word {
+		end++
+	}
+	return
+}
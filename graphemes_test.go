@@ -0,0 +1,107 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGraphemeBoundaries(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		bounds []int
+	}{
+		{"empty", "", []int{0}},
+		{"ascii", "abc", []int{0, 1, 2, 3}},
+		{"crlf", "a\r\nb", []int{0, 1, 3, 4}},
+		{"combining accent", "e\u0301x", []int{0, 2, 3}},
+		{"skin tone", "\U0001F44D\U0001F3FDx", []int{0, 2, 3}},
+		// family: man, ZWJ, woman, ZWJ, girl
+		{"zwj sequence", "\U0001F468\u200D\U0001F469\u200D\U0001F467!", []int{0, 5, 6}},
+		{"zwj with variation selector", "\u2764\uFE0F\u200D\U0001F525", []int{0, 4}},
+		{"zwj after a letter", "a\u200D\U0001F525", []int{0, 2, 3}},
+		{"flag", "\U0001F1EB\U0001F1F7", []int{0, 2}},
+		{"two flags", "\U0001F1EB\U0001F1F7\U0001F1EF\U0001F1F5", []int{0, 2, 4}},
+		{"odd indicator", "\U0001F1EB\U0001F1F7\U0001F1EF", []int{0, 2, 3}},
+		{"hangul jamo", "\u1100\u1161\u11A8a", []int{0, 3, 4}},
+		{"hangul syllable", "\uAC00\u11A8\uAC01", []int{0, 2, 3}},
+	}
+	for _, test := range tests {
+		got := GraphemeBoundaries([]rune(test.text))
+		if !reflect.DeepEqual(got, test.bounds) {
+			t.Errorf("%s: boundaries %v, want %v", test.name, got, test.bounds)
+		}
+	}
+}
+
+func TestGraphemeMoves(t *testing.T) {
+	// x, flag, x, family, x
+	runes := []rune("x\U0001F1EB\U0001F1F7x\U0001F468\u200D\U0001F469\u200D\U0001F467x")
+	tests := []struct {
+		i          int
+		next, prev int
+		snap       int
+	}{
+		{0, 1, 0, 0},
+		{1, 3, 0, 1},
+		{2, 3, 1, 1},
+		{3, 4, 1, 3},
+		{4, 9, 3, 4},
+		{6, 9, 4, 4},
+		{9, 10, 4, 9},
+		{10, 10, 9, 10},
+	}
+	for _, test := range tests {
+		if got := NextGrapheme(runes, test.i); got != test.next {
+			t.Errorf("NextGrapheme(%d) = %d, want %d", test.i, got, test.next)
+		}
+		if got := PrevGrapheme(runes, test.i); got != test.prev {
+			t.Errorf("PrevGrapheme(%d) = %d, want %d", test.i, got, test.prev)
+		}
+		if got := SnapToGrapheme(runes, test.i); got != test.snap {
+			t.Errorf("SnapToGrapheme(%d) = %d, want %d", test.i, got, test.snap)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	runes := []rune("hi, caf\u00E9_2  x")
+	tests := []struct {
+		i          int
+		next, prev int
+		start, end int
+	}{
+		{0, 2, 0, 0, 2},
+		{2, 10, 0, 2, 4},
+		{5, 10, 4, 4, 10},
+		{10, 13, 4, 10, 12},
+		{13, 13, 12, 12, 13},
+	}
+	for _, test := range tests {
+		if got := NextWord(runes, test.i); got != test.next {
+			t.Errorf("NextWord(%d) = %d, want %d", test.i, got, test.next)
+		}
+		if got := PrevWord(runes, test.i); got != test.prev {
+			t.Errorf("PrevWord(%d) = %d, want %d", test.i, got, test.prev)
+		}
+		if start, end := WordAt(runes, test.i); start != test.start || end != test.end {
+			t.Errorf("WordAt(%d) = %d, %d, want %d, %d", test.i, start, end, test.start, test.end)
+		}
+	}
+}
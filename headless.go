@@ -0,0 +1,154 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/go.wde"
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// A HeadlessWindow is a wde.Window that draws into memory, so that a UI
+// can be run, and its output inspected, without a display. To use it for
+// every window, set
+//
+//	uik.WindowGenerator = uik.NewHeadlessWindow
+//
+// Setting DefaultScale as well renders the UI as it would appear on a
+// high-density display.
+type HeadlessWindow struct {
+	mu     sync.Mutex
+	title  string
+	screen *headlessImage
+	events chan interface{}
+	closed bool
+	// closed with the window, so that blocked sends give up
+	done chan bool
+	// held by sends, so that events isn't closed under them
+	sending sync.RWMutex
+
+	// Flushes receives the rectangles passed to each FlushImage call, if
+	// there is room for them.
+	Flushes chan []image.Rectangle
+}
+
+type headlessImage struct {
+	*image.RGBA
+}
+
+func (im headlessImage) CopyRGBA(src *image.RGBA, bounds image.Rectangle) {
+	draw.Draw(im.RGBA, bounds, src, src.Bounds().Min, draw.Src)
+}
+
+func NewHeadlessWindow(parent wde.Window, width, height int) (w wde.Window, err error) {
+	hw := new(HeadlessWindow)
+	hw.screen = &headlessImage{image.NewRGBA(image.Rect(0, 0, width, height))}
+	hw.events = make(chan interface{}, 20)
+	hw.done = make(chan bool)
+	hw.Flushes = make(chan []image.Rectangle, 1)
+	w = hw
+	return
+}
+
+func (hw *HeadlessWindow) SetTitle(title string) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	hw.title = title
+}
+
+func (hw *HeadlessWindow) SetSize(width, height int) {
+	hw.mu.Lock()
+	hw.screen = &headlessImage{image.NewRGBA(image.Rect(0, 0, width, height))}
+	hw.mu.Unlock()
+
+	hw.SendEvent(wde.ResizeEvent{
+		Width:  width,
+		Height: height,
+	})
+}
+
+func (hw *HeadlessWindow) Size() (width, height int) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	s := hw.screen.Bounds().Size()
+	return s.X, s.Y
+}
+
+func (hw *HeadlessWindow) LockSize(lock bool) {}
+
+func (hw *HeadlessWindow) Show() {}
+
+func (hw *HeadlessWindow) Screen() (im wde.Image) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	return hw.screen
+}
+
+func (hw *HeadlessWindow) FlushImage(bounds ...image.Rectangle) {
+	select {
+	case hw.Flushes <- bounds:
+	default:
+	}
+}
+
+func (hw *HeadlessWindow) EventChan() (events <-chan interface{}) {
+	return hw.events
+}
+
+func (hw *HeadlessWindow) Close() (err error) {
+	hw.mu.Lock()
+	if hw.closed {
+		hw.mu.Unlock()
+		return
+	}
+	hw.closed = true
+	close(hw.done)
+	hw.mu.Unlock()
+
+	// wait for sends under way, which give up now that done is closed
+	hw.sending.Lock()
+	close(hw.events)
+	hw.sending.Unlock()
+	return
+}
+
+// SendEvent feeds a wde event to the window, as if it came from the user.
+// It waits for room for the event, unless the window is closed.
+func (hw *HeadlessWindow) SendEvent(e interface{}) {
+	hw.sending.RLock()
+	defer hw.sending.RUnlock()
+	hw.mu.Lock()
+	closed := hw.closed
+	hw.mu.Unlock()
+	if closed {
+		return
+	}
+	select {
+	case hw.events <- e:
+	case <-hw.done:
+	}
+}
+
+// Snapshot returns a copy of what has been drawn to the window.
+func (hw *HeadlessWindow) Snapshot() (img *image.RGBA) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	img = image.NewRGBA(hw.screen.Bounds())
+	draw.Draw(img, img.Bounds(), hw.screen, img.Bounds().Min, draw.Src)
+	return
+}
@@ -0,0 +1,171 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+	"time"
+)
+
+// fillDrawer fills whatever it is asked to draw, and reports the size of
+// the buffer it was given.
+type fillDrawer struct {
+	c       color.Color
+	buffers chan image.Rectangle
+}
+
+func (d fillDrawer) Draw(buffer draw.Image, invalidRects RectSet) {
+	draw.Draw(buffer, buffer.Bounds(), image.NewUniform(d.c), image.Point{}, draw.Src)
+	select {
+	case d.buffers <- buffer.Bounds():
+	default:
+	}
+}
+
+func TestHeadlessScale2(t *testing.T) {
+	oldGenerator, oldScale := WindowGenerator, DefaultScale
+	WindowGenerator, DefaultScale = NewHeadlessWindow, 2
+	defer func() {
+		WindowGenerator, DefaultScale = oldGenerator, oldScale
+	}()
+
+	wf, err := NewWindow(nil, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw := wf.W.(*HeadlessWindow)
+	defer hw.Close()
+	if w, h := hw.Size(); w != 20 || h != 20 {
+		t.Fatalf("window is %dx%d pixels, want 20x20", w, h)
+	}
+
+	red := color.RGBA{255, 0, 0, 255}
+	child := new(Block)
+	child.Initialize()
+	buffers := make(chan image.Rectangle, 10)
+	child.Drawer = fillDrawer{red, buffers}
+	go func() {
+		for {
+			select {
+			case e := <-child.UserEvents:
+				child.HandleEvent(e)
+			case e := <-child.ResizeEvents:
+				child.DoResizeEvent(e)
+			}
+		}
+	}()
+
+	// a pane that puts the child at bounds that fall between pixels, once
+	// it knows its scale
+	pane := new(Foundation)
+	pane.Initialize()
+	place := func() {
+		pane.PlaceBlock(child, geom.Rect{
+			Min: geom.Coord{1.3, 1.3},
+			Max: geom.Coord{4.2, 4.2},
+		})
+	}
+	go func() {
+		for {
+			select {
+			case e := <-pane.UserEvents:
+				pane.HandleEvent(e)
+				if _, ok := e.(ScaleEvent); ok {
+					place()
+				}
+			case e := <-pane.BlockInvalidations:
+				pane.DoBlockInvalidation(e)
+			case e := <-pane.ResizeEvents:
+				pane.DoResizeEvent(e)
+				place()
+			}
+		}
+	}()
+	wf.SetPane(&pane.Block)
+	wf.Show()
+
+	// at 2x, 1.3 snaps to 1.5 and 4.2 to 4, so the child covers pixels 3
+	// through 7, in a buffer 5 pixels across
+	isRed := func(img *image.RGBA, x, y int) bool {
+		return img.RGBAAt(x, y) == red
+	}
+	timeout := time.After(5 * time.Second)
+	var snap *image.RGBA
+	for {
+		select {
+		case <-hw.Flushes:
+		case <-timeout:
+			t.Fatal("the child was never drawn where it belongs")
+		}
+		snap = hw.Snapshot()
+		if isRed(snap, 3, 3) && isRed(snap, 7, 7) {
+			break
+		}
+	}
+	if b := snap.Bounds(); b.Dx() != 20 || b.Dy() != 20 {
+		t.Errorf("snapshot is %v, want 20x20", b)
+	}
+	for _, p := range []image.Point{{2, 3}, {3, 2}, {8, 7}, {7, 8}, {2, 2}, {8, 8}} {
+		if isRed(snap, p.X, p.Y) {
+			t.Errorf("pixel %v is drawn, outside the snapped bounds", p)
+		}
+	}
+	var last image.Rectangle
+	for drained := false; !drained; {
+		select {
+		case last = <-buffers:
+		default:
+			drained = true
+		}
+	}
+	if last.Dx() != 5 || last.Dy() != 5 {
+		t.Errorf("child buffer is %v, want 5x5 pixels", last)
+	}
+}
+
+func TestHeadlessCloseWhileSending(t *testing.T) {
+	w, err := NewHeadlessWindow(nil, 10, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw := w.(*HeadlessWindow)
+	// nothing reads the events, so once the buffer is full sends wait
+	sent := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			hw.SendEvent(i)
+		}
+		sent <- true
+	}()
+	closed := make(chan bool)
+	go func() {
+		hw.Snapshot()
+		hw.Close()
+		closed <- true
+	}()
+	for _, ch := range []chan bool{closed, sent} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("a send blocked Close")
+		}
+	}
+}
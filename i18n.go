@@ -0,0 +1,508 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A PluralCategory is which form of a message to use for a count, as the
+// Unicode CLDR names them.
+type PluralCategory string
+
+const (
+	PluralZero  PluralCategory = "zero"
+	PluralOne   PluralCategory = "one"
+	PluralTwo   PluralCategory = "two"
+	PluralFew   PluralCategory = "few"
+	PluralMany  PluralCategory = "many"
+	PluralOther PluralCategory = "other"
+)
+
+// A LocaleInfo is how a locale writes numbers, dates and plurals.
+type LocaleInfo struct {
+	Decimal, Group string
+	// Layouts for time.Format.
+	Date, Time string
+	Direction  Direction
+	Plural     func(n int) PluralCategory
+}
+
+func pluralNone(n int) PluralCategory {
+	return PluralOther
+}
+
+func pluralOne(n int) PluralCategory {
+	if n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+func pluralZeroOne(n int) PluralCategory {
+	if n == 0 || n == 1 {
+		return PluralOne
+	}
+	return PluralOther
+}
+
+func pluralEastSlavic(n int) PluralCategory {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return PluralOne
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return PluralFew
+	}
+	return PluralMany
+}
+
+func pluralPolish(n int) PluralCategory {
+	switch {
+	case n == 1:
+		return PluralOne
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return PluralFew
+	}
+	return PluralMany
+}
+
+func pluralArabic(n int) PluralCategory {
+	switch {
+	case n == 0:
+		return PluralZero
+	case n == 1:
+		return PluralOne
+	case n == 2:
+		return PluralTwo
+	case n%100 >= 3 && n%100 <= 10:
+		return PluralFew
+	case n%100 >= 11:
+		return PluralMany
+	}
+	return PluralOther
+}
+
+func pluralHebrew(n int) PluralCategory {
+	switch n {
+	case 1:
+		return PluralOne
+	case 2:
+		return PluralTwo
+	}
+	return PluralOther
+}
+
+var (
+	localeGuard sync.RWMutex
+	locales     = map[string]*LocaleInfo{
+		"en":    {".", ",", "01/02/2006", "3:04 PM", DirLTR, pluralOne},
+		"en-GB": {".", ",", "02/01/2006", "15:04", DirLTR, pluralOne},
+		"de":    {",", ".", "02.01.2006", "15:04", DirLTR, pluralOne},
+		"nl":    {",", ".", "02-01-2006", "15:04", DirLTR, pluralOne},
+		"sv":    {",", " ", "2006-01-02", "15:04", DirLTR, pluralOne},
+		"it":    {",", ".", "02/01/2006", "15:04", DirLTR, pluralOne},
+		"es":    {",", ".", "02/01/2006", "15:04", DirLTR, pluralOne},
+		"pt":    {",", ".", "02/01/2006", "15:04", DirLTR, pluralOne},
+		"pt-BR": {",", ".", "02/01/2006", "15:04", DirLTR, pluralZeroOne},
+		"fr":    {",", " ", "02/01/2006", "15:04", DirLTR, pluralZeroOne},
+		"ru":    {",", " ", "02.01.2006", "15:04", DirLTR, pluralEastSlavic},
+		"uk":    {",", " ", "02.01.2006", "15:04", DirLTR, pluralEastSlavic},
+		"pl":    {",", " ", "02.01.2006", "15:04", DirLTR, pluralPolish},
+		"ar":    {"٫", "٬", "02/01/2006", "3:04 PM", DirRTL, pluralArabic},
+		"he":    {".", ",", "02.01.2006", "15:04", DirRTL, pluralHebrew},
+		"ja":    {".", ",", "2006/01/02", "15:04", DirLTR, pluralNone},
+		"zh":    {".", ",", "2006/01/02", "15:04", DirLTR, pluralNone},
+		"ko":    {".", ",", "2006. 01. 02.", "15:04", DirLTR, pluralNone},
+	}
+)
+
+// RegisterLocale adds or replaces what LookupLocale returns for tag, such
+// as "en" or "en-GB".
+func RegisterLocale(tag string, info *LocaleInfo) {
+	localeGuard.Lock()
+	defer localeGuard.Unlock()
+	locales[tag] = info
+}
+
+// LookupLocale returns how tag writes things, trying its language alone if
+// there is nothing for the whole tag, and English if there is nothing for
+// the language either.
+func LookupLocale(tag string) (info *LocaleInfo) {
+	localeGuard.RLock()
+	defer localeGuard.RUnlock()
+	for _, t := range localeChain(tag, "en") {
+		if info = locales[t]; info != nil {
+			return
+		}
+	}
+	return
+}
+
+// localeChain lists the tags to try for tag, most specific first, then
+// fallback.
+func localeChain(tag, fallback string) (tags []string) {
+	tag = normalizeLocale(tag)
+	for tag != "" {
+		tags = append(tags, tag)
+		i := strings.LastIndex(tag, "-")
+		if i == -1 {
+			break
+		}
+		tag = tag[:i]
+	}
+	if fallback != "" {
+		tags = append(tags, fallback)
+	}
+	return
+}
+
+// normalizeLocale turns POSIX locale names, like "de_DE.UTF-8", into tags
+// like "de-DE".
+func normalizeLocale(tag string) string {
+	if i := strings.IndexAny(tag, ".@"); i != -1 {
+		tag = tag[:i]
+	}
+	tag = strings.Replace(tag, "_", "-", -1)
+	if tag == "C" || tag == "POSIX" {
+		tag = ""
+	}
+	return tag
+}
+
+// MessageForms are a message's text for each plural category. A message
+// with no plural forms has only PluralOther. In JSON, that may be written
+// as just a string.
+type MessageForms map[PluralCategory]string
+
+func (mf *MessageForms) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*mf = MessageForms{PluralOther: s}
+		return
+	}
+	forms := map[PluralCategory]string{}
+	err = json.Unmarshal(data, &forms)
+	*mf = forms
+	return
+}
+
+// A Catalog is the messages of one locale. In JSON, it looks like
+//
+//	{
+//		"Locale": "de",
+//		"Messages": {
+//			"greeting": "Hallo, {name}!",
+//			"files": {"one": "{count} Datei", "other": "{count} Dateien"}
+//		}
+//	}
+//
+// where {name} is replaced by the argument of that name. The count
+// argument picks the plural form.
+type Catalog struct {
+	Locale   string
+	Messages map[string]MessageForms
+}
+
+func ReadCatalog(r io.Reader) (c *Catalog, err error) {
+	c = new(Catalog)
+	dec := json.NewDecoder(r)
+	err = dec.Decode(c)
+	c.Locale = normalizeLocale(c.Locale)
+	return
+}
+
+func LoadCatalog(path string) (c *Catalog, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	c, err = ReadCatalog(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// A Translator finds messages for the current locale in its catalogs, and
+// formats values the way the locale does. It is safe for use from
+// multiple goroutines.
+type Translator struct {
+	mu       sync.RWMutex
+	locale   string
+	fallback string
+	catalogs map[string]*Catalog
+	windows  map[*Block]bool
+}
+
+// NewTranslator makes a translator for locale, using fallback for
+// messages that locale has no catalog entry for.
+func NewTranslator(locale, fallback string) (t *Translator) {
+	t = new(Translator)
+	t.locale = normalizeLocale(locale)
+	t.fallback = normalizeLocale(fallback)
+	if t.locale == "" {
+		t.locale = t.fallback
+	}
+	t.catalogs = map[string]*Catalog{}
+	t.windows = map[*Block]bool{}
+	return
+}
+
+// AddCatalog adds c's messages to those of its locale, replacing any with
+// the same keys.
+func (t *Translator) AddCatalog(c *Catalog) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	existing := t.catalogs[c.Locale]
+	if existing == nil {
+		existing = &Catalog{
+			Locale:   c.Locale,
+			Messages: map[string]MessageForms{},
+		}
+		t.catalogs[c.Locale] = existing
+	}
+	for key, forms := range c.Messages {
+		existing.Messages[key] = forms
+	}
+}
+
+// LoadDir adds every catalog in the .json files of dir.
+func (t *Translator) LoadDir(dir string) (err error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return
+	}
+	for _, path := range paths {
+		var c *Catalog
+		c, err = LoadCatalog(path)
+		if err != nil {
+			return
+		}
+		t.AddCatalog(c)
+	}
+	return
+}
+
+func (t *Translator) Locale() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.locale
+}
+
+// SetLocale changes the current locale, and sends a LocaleEvent through
+// every window, so that their blocks show the new locale's text.
+func (t *Translator) SetLocale(locale string) {
+	locale = normalizeLocale(locale)
+	t.mu.Lock()
+	if locale == t.locale {
+		t.mu.Unlock()
+		return
+	}
+	t.locale = locale
+	var windows []*Block
+	for w := range t.windows {
+		windows = append(windows, w)
+	}
+	t.mu.Unlock()
+
+	for _, w := range windows {
+		w.SendEvent(LocaleEvent{
+			Locale: locale,
+		})
+	}
+}
+
+func (t *Translator) addWindow(w *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.windows[w] = true
+}
+
+func (t *Translator) removeWindow(w *Block) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.windows, w)
+}
+
+// Info returns how the current locale writes things.
+func (t *Translator) Info() *LocaleInfo {
+	return LookupLocale(t.Locale())
+}
+
+// Translate returns the message for key in the current locale, with its
+// placeholders filled in from args. Numbers and times are formatted for
+// the locale. If no catalog has the key, the key itself is returned.
+func (t *Translator) Translate(key string, args map[string]interface{}) string {
+	t.mu.RLock()
+	var forms MessageForms
+	var found string
+	for _, tag := range localeChain(t.locale, t.fallback) {
+		if c := t.catalogs[tag]; c != nil {
+			if forms = c.Messages[key]; forms != nil {
+				found = tag
+				break
+			}
+		}
+	}
+	t.mu.RUnlock()
+	if forms == nil {
+		return key
+	}
+
+	text, ok := forms[PluralOther]
+	if n, isInt := toInt(args["count"]); isInt {
+		if plural, pok := forms[LookupLocale(found).Plural(n)]; pok {
+			text, ok = plural, true
+		}
+	}
+	if !ok {
+		// no other form, so take any
+		for _, text = range forms {
+			break
+		}
+	}
+	return t.fill(text, args)
+}
+
+// fill replaces each {name} in text with args[name]. Unknown names are
+// left as they are.
+func (t *Translator) fill(text string, args map[string]interface{}) string {
+	var buf []string
+	for {
+		open := strings.Index(text, "{")
+		if open == -1 {
+			break
+		}
+		end := strings.Index(text[open:], "}")
+		if end == -1 {
+			break
+		}
+		name := text[open+1 : open+end]
+		value, ok := args[name]
+		if !ok {
+			buf = append(buf, text[:open+end+1])
+		} else {
+			buf = append(buf, text[:open], t.Format(value))
+		}
+		text = text[open+end+1:]
+	}
+	buf = append(buf, text)
+	return strings.Join(buf, "")
+}
+
+func toInt(v interface{}) (n int, ok bool) {
+	ok = true
+	switch v := v.(type) {
+	case int:
+		n = v
+	case int32:
+		n = int(v)
+	case int64:
+		n = int(v)
+	case uint:
+		n = int(v)
+	case uint32:
+		n = int(v)
+	case uint64:
+		n = int(v)
+	default:
+		ok = false
+	}
+	return
+}
+
+// Format writes v as the current locale does, if it is a number or a
+// time, and with fmt otherwise.
+func (t *Translator) Format(v interface{}) string {
+	if n, ok := toInt(v); ok {
+		return t.FormatInt(int64(n))
+	}
+	switch v := v.(type) {
+	case float64:
+		return t.FormatNumber(v, -1)
+	case float32:
+		return t.FormatNumber(float64(v), -1)
+	case time.Time:
+		return t.FormatDate(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func (t *Translator) FormatInt(n int64) string {
+	return t.FormatNumber(float64(n), 0)
+}
+
+// FormatNumber writes x with the locale's decimal and grouping marks, and
+// the given number of decimal places. With decimals less than zero, it
+// uses as many as x needs.
+func (t *Translator) FormatNumber(x float64, decimals int) string {
+	info := t.Info()
+	s := strconv.FormatFloat(x, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i != -1 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	var groups []string
+	for len(whole) > 3 {
+		groups = append([]string{whole[len(whole)-3:]}, groups...)
+		whole = whole[:len(whole)-3]
+	}
+	groups = append([]string{whole}, groups...)
+	s = sign + strings.Join(groups, info.Group)
+	if frac != "" {
+		s += info.Decimal + frac
+	}
+	return s
+}
+
+func (t *Translator) FormatDate(d time.Time) string {
+	return d.Format(t.Info().Date)
+}
+
+func (t *Translator) FormatTime(d time.Time) string {
+	return d.Format(t.Info().Time)
+}
+
+// Translations is the translator widgets use. It starts in the locale
+// given by the LANG environment variable, falling back to English.
+var Translations = NewTranslator(os.Getenv("LANG"), "en")
+
+// A Message is text to be looked up in Translations when it is shown, so
+// that it follows changes of locale.
+type Message struct {
+	Key string
+	// Values for the message's placeholders. An integer "count" also picks
+	// the plural form.
+	Args map[string]interface{}
+}
+
+func (m *Message) String() string {
+	return Translations.Translate(m.Key, m.Args)
+}
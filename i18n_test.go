@@ -0,0 +1,169 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlurals(t *testing.T) {
+	tests := []struct {
+		locale string
+		n      int
+		plural PluralCategory
+	}{
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 2, PluralOther},
+		{"en", 11, PluralOther},
+		{"en-US", 1, PluralOne},
+
+		{"fr", 0, PluralOne},
+		{"fr", 1, PluralOne},
+		{"fr", 2, PluralOther},
+		{"fr", 100, PluralOther},
+
+		{"ru", 1, PluralOne},
+		{"ru", 2, PluralFew},
+		{"ru", 4, PluralFew},
+		{"ru", 5, PluralMany},
+		{"ru", 11, PluralMany},
+		{"ru", 12, PluralMany},
+		{"ru", 21, PluralOne},
+		{"ru", 22, PluralFew},
+		{"ru", 111, PluralMany},
+		{"ru", 0, PluralMany},
+
+		{"ar", 0, PluralZero},
+		{"ar", 1, PluralOne},
+		{"ar", 2, PluralTwo},
+		{"ar", 3, PluralFew},
+		{"ar", 10, PluralFew},
+		{"ar", 11, PluralMany},
+		{"ar", 99, PluralMany},
+		{"ar", 100, PluralOther},
+		{"ar", 102, PluralOther},
+		{"ar", 103, PluralFew},
+		{"ar", 111, PluralMany},
+	}
+	for _, test := range tests {
+		if got := LookupLocale(test.locale).Plural(test.n); got != test.plural {
+			t.Errorf("%s, %d: %s, want %s", test.locale, test.n, got, test.plural)
+		}
+	}
+}
+
+func TestLookupLocale(t *testing.T) {
+	tests := []struct {
+		tag  string
+		date string
+	}{
+		{"en", "01/02/2006"},
+		{"en-GB", "02/01/2006"},
+		{"en_GB.UTF-8", "02/01/2006"},
+		{"de-AT", "02.01.2006"},
+		{"C", "01/02/2006"},
+		{"xx", "01/02/2006"},
+	}
+	for _, test := range tests {
+		if got := LookupLocale(test.tag).Date; got != test.date {
+			t.Errorf("LookupLocale(%q).Date = %q, want %q", test.tag, got, test.date)
+		}
+	}
+}
+
+var testCatalogs = []string{`{
+	"Locale": "en",
+	"Messages": {
+		"greeting": "Hello, {name}!",
+		"files": {"one": "{count} file", "other": "{count} files"}
+	}
+}`, `{
+	"Locale": "ru_RU.UTF-8",
+	"Messages": {
+		"files": {"one": "{count} файл", "few": "{count} файла", "many": "{count} файлов"}
+	}
+}`, `{
+	"Locale": "ar",
+	"Messages": {
+		"files": {"zero": "لا ملفات", "one": "ملف", "two": "ملفان", "few": "{count} ملفات", "many": "{count} ملفًا", "other": "{count} ملف"}
+	}
+}`}
+
+func TestTranslate(t *testing.T) {
+	tr := NewTranslator("en", "en")
+	for _, text := range testCatalogs {
+		c, err := ReadCatalog(strings.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr.AddCatalog(c)
+	}
+	tests := []struct {
+		locale string
+		key    string
+		args   map[string]interface{}
+		text   string
+	}{
+		{"en", "greeting", map[string]interface{}{"name": "Ann"}, "Hello, Ann!"},
+		{"en", "greeting", nil, "Hello, {name}!"},
+		{"en", "files", map[string]interface{}{"count": 1}, "1 file"},
+		{"en", "files", map[string]interface{}{"count": 1234}, "1,234 files"},
+		{"en", "missing", nil, "missing"},
+		// Russian has no greeting, so English is used
+		{"ru-RU", "greeting", map[string]interface{}{"name": "Ann"}, "Hello, Ann!"},
+		{"ru-RU", "files", map[string]interface{}{"count": 21}, "21 файл"},
+		{"ru-RU", "files", map[string]interface{}{"count": 3}, "3 файла"},
+		{"ru-RU", "files", map[string]interface{}{"count": 1000}, "1\u00A0000 файлов"},
+		// a catalog for ru-RU isn't used for ru alone
+		{"ru", "files", map[string]interface{}{"count": 3}, "3 files"},
+		{"ar", "files", map[string]interface{}{"count": 0}, "لا ملفات"},
+		{"ar", "files", map[string]interface{}{"count": 2}, "ملفان"},
+		{"ar", "files", map[string]interface{}{"count": 5}, "5 ملفات"},
+		{"ar", "files", map[string]interface{}{"count": 11}, "11 ملفًا"},
+		{"ar", "files", map[string]interface{}{"count": 100}, "100 ملف"},
+	}
+	for _, test := range tests {
+		tr.SetLocale(test.locale)
+		if got := tr.Translate(test.key, test.args); got != test.text {
+			t.Errorf("%s, %s, %v: %q, want %q", test.locale, test.key, test.args, got, test.text)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		locale   string
+		x        float64
+		decimals int
+		text     string
+	}{
+		{"en", 1234567.5, 1, "1,234,567.5"},
+		{"en", -1234, 0, "-1,234"},
+		{"en", 12, -1, "12"},
+		{"de", 1234.25, 2, "1.234,25"},
+		{"fr", 1234.5, -1, "1\u202F234,5"},
+		{"ar", 1234.5, 1, "1٬234٫5"},
+	}
+	for _, test := range tests {
+		tr := NewTranslator(test.locale, "en")
+		if got := tr.FormatNumber(test.x, test.decimals); got != test.text {
+			t.Errorf("%s: FormatNumber(%v, %d) = %q, want %q", test.locale, test.x, test.decimals, got, test.text)
+		}
+	}
+}
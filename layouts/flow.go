@@ -32,8 +32,13 @@ type Flow struct {
 
 	sizeHint uik.SizeHint
 
+	rightToLeft bool
+
 	Add    chan *uik.Block
 	Remove chan *uik.Block
+	// Send true to flow blocks from the right edge leftwards, for right to
+	// left languages.
+	SetRightToLeft chan bool
 }
 
 func NewFlow() (f *Flow) {
@@ -47,9 +52,11 @@ func NewFlow() (f *Flow) {
 
 func (f *Flow) Initialize() {
 	f.Foundation.Initialize()
+	f.Type = "layouts.Flow"
 	f.DrawOp = draw.Over
 	f.Add = make(chan *uik.Block, 10)
 	f.Remove = make(chan *uik.Block, 10)
+	f.SetRightToLeft = make(chan bool, 1)
 	f.childIndices = map[*uik.Block]int{}
 }
 
@@ -77,20 +84,30 @@ func (f *Flow) reflow() {
 			//println("skip", child)
 			continue
 		}
+		width := ratioX * csh.PreferredSize.X
+		prefY := csh.PreferredSize.Y
+		if csh.HeightForWidth != nil {
+			// wrapped text, say, is as tall as the width it gets makes it
+			prefY = csh.HeightForWidth(width)
+		}
 		cbounds := geom.Rect{geom.Coord{left, 0}, geom.Coord{}}
-		if csh.PreferredSize.Y <= renderSize.Y {
-			cbounds.Max.Y = csh.PreferredSize.Y
+		if prefY <= renderSize.Y {
+			cbounds.Max.Y = prefY
 		} else if csh.MinSize.Y <= renderSize.Y {
 			cbounds.Max.Y = renderSize.Y
 		} else {
 			cbounds.Max.Y = csh.MinSize.Y
 		}
-		cbounds.Max.X = left + ratioX*csh.PreferredSize.X
+		cbounds.Max.X = left + width
+		left = cbounds.Max.X
+
+		if f.rightToLeft {
+			cbounds.Min.X, cbounds.Max.X = renderSize.X-cbounds.Max.X, renderSize.X-cbounds.Min.X
+		}
 
 		f.PlaceBlock(child, cbounds)
 
 		//fmt.Println("flow", cbounds.Width(), cbounds.Height())
-		left = cbounds.Max.X
 	}
 	f.Invalidate()
 	// fmt.Println()
@@ -163,6 +180,12 @@ func (f *Flow) HandleEvents() {
 
 			f.RemoveBlock(b)
 
+			f.reflow()
+		case rtl := <-f.SetRightToLeft:
+			if rtl == f.rightToLeft {
+				break
+			}
+			f.rightToLeft = rtl
 			f.reflow()
 		}
 	}
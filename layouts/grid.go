@@ -84,6 +84,10 @@ type removeBlock *uik.Block
 
 type GridConfig struct {
 	Components map[string]GridComponent
+	// If true, column 0 is on the right, and AnchorLeft and AnchorRight
+	// swap, so that a grid for a left to right language can be used for a
+	// right to left one.
+	RightToLeft bool
 }
 
 func ReadGridConfig(r io.Reader) (cfg GridConfig, err error) {
@@ -103,7 +107,10 @@ type GridEngine struct {
 
 	childrenHints          map[*uik.Block]uik.SizeHint
 	childrenGridComponents map[*uik.Block]GridComponent
-	config                 GridConfig
+	// blocks added with AddName, which follow their components when the
+	// config changes
+	childrenNames map[*uik.Block]string
+	config        GridConfig
 
 	vflex, hflex   *flex
 	velems, helems map[*uik.Block]*elem
@@ -123,6 +130,7 @@ func NewGridEngine(config GridConfig) (g *GridEngine) {
 
 	g.childrenHints = make(map[*uik.Block]uik.SizeHint)
 	g.childrenGridComponents = map[*uik.Block]GridComponent{}
+	g.childrenNames = map[*uik.Block]string{}
 
 	g.hflex = &flex{}
 	g.vflex = &flex{}
@@ -154,8 +162,14 @@ func (g *GridEngine) SetConfig(cfg GridConfig) {
 	g.layouter.Config(cfg)
 }
 
+// addBlock adds block to the grid, or moves it if it is already there.
 func (g *GridEngine) addBlock(block *uik.Block, bd GridComponent) {
 
+	if _, ok := g.childrenGridComponents[block]; ok {
+		g.hflex.rem(g.helems[block])
+		g.vflex.rem(g.velems[block])
+	}
+
 	g.layouter.AddBlock(block)
 	g.childrenGridComponents[block] = bd
 
@@ -172,17 +186,27 @@ func (g *GridEngine) addBlock(block *uik.Block, bd GridComponent) {
 
 	g.hflex.add(helem)
 	g.vflex.add(velem)
+
+	if _, ok := g.childrenHints[block]; ok {
+		g.reflex(block)
+	}
 }
 
 func (g *GridEngine) remBlock(b *uik.Block) {
+	if _, ok := g.childrenGridComponents[b]; !ok {
+		return
+	}
 	g.layouter.RemoveBlock(b)
 
-	delete(g.childrenHints, b)
-	delete(g.childrenGridComponents, b)
-
 	g.hflex.rem(g.helems[b])
 	g.vflex.rem(g.velems[b])
 
+	delete(g.childrenHints, b)
+	delete(g.childrenGridComponents, b)
+	delete(g.childrenNames, b)
+	delete(g.helems, b)
+	delete(g.velems, b)
+
 	g.layouter.Invalidate()
 }
 
@@ -245,6 +269,33 @@ func (g *GridEngine) GetLayout(size geom.Coord) (layout Layout) {
 	layout = make(Layout)
 
 	_, minXs, maxXs := g.hflex.constrain(size.X)
+
+	// blocks like wrapped labels want the height that suits the width they
+	// get, so rows are sized once the columns are
+	prefYs := map[*uik.Block]float64{}
+	for child, csh := range g.childrenHints {
+		prefYs[child] = csh.PreferredSize.Y
+		if csh.HeightForWidth == nil {
+			continue
+		}
+		bd := g.childrenGridComponents[child]
+		width := maxXs[bd.GridX+bd.ExtraX] - minXs[bd.GridX]
+		if !bd.AnchorLeft || !bd.AnchorRight {
+			width = math.Min(width, math.Min(csh.MaxSize.X, csh.PreferredSize.X))
+		}
+		prefYs[child] = csh.HeightForWidth(width)
+		if bd.PreferredSize.Y != 0 {
+			continue
+		}
+		velem := g.velems[child]
+		prefY := math.Min(velem.maxSize, math.Max(velem.minSize, prefYs[child]))
+		if prefY != velem.prefSize {
+			velem.prefSize = prefY
+			velem.fix()
+			// the rows last worked out may no longer be the best
+			g.vflex.length = -1
+		}
+	}
 	_, minYs, maxYs := g.vflex.constrain(size.Y)
 
 	// if g.Block.ID == 2 {
@@ -306,8 +357,8 @@ func (g *GridEngine) GetLayout(size geom.Coord) (layout Layout) {
 				gridBounds.Min.X += diff
 			}
 		}
-		if gridSizeY > csh.PreferredSize.Y {
-			diff := gridSizeY - csh.PreferredSize.Y
+		if gridSizeY > prefYs[child] {
+			diff := gridSizeY - prefYs[child]
 			if !bd.AnchorTop && !bd.AnchorBottom {
 				gridBounds.Min.Y += diff / 2
 				gridBounds.Max.Y -= diff / 2
@@ -320,6 +371,10 @@ func (g *GridEngine) GetLayout(size geom.Coord) (layout Layout) {
 			}
 		}
 
+		if g.config.RightToLeft {
+			gridBounds.Min.X, gridBounds.Max.X = size.X-gridBounds.Max.X, size.X-gridBounds.Min.X
+		}
+
 		layout[child] = gridBounds
 
 	}
@@ -332,8 +387,17 @@ func (g *GridEngine) ConfigUnsafe(cfg interface{}) {
 	switch cfg := cfg.(type) {
 	case GridConfig:
 		g.config = cfg
+		for b, name := range g.childrenNames {
+			componentConfig, ok := g.config.Components[name]
+			if !ok {
+				log.Print("GridEngine with Layouter", g.layouter.ID, ": unknown name", name)
+				continue
+			}
+			g.addBlock(b, componentConfig)
+		}
 	case blockConfigPair:
 		g.addBlock(cfg.block, cfg.config)
+		delete(g.childrenNames, cfg.block)
 	case blockNamePair:
 		componentConfig, ok := g.config.Components[cfg.name]
 		if !ok {
@@ -341,5 +405,8 @@ func (g *GridEngine) ConfigUnsafe(cfg interface{}) {
 			return
 		}
 		g.addBlock(cfg.block, componentConfig)
+		g.childrenNames[cfg.block] = cfg.name
+	case removeBlock:
+		g.remBlock(cfg)
 	}
 }
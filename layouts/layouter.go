@@ -73,6 +73,7 @@ func NewLayouter(engine LayoutEngine) (l *Layouter) {
 
 func (l *Layouter) Initialize() {
 	l.Foundation.Initialize()
+	l.Type = "layouts.Layouter"
 	l.config = make(chan interface{}, 1)
 }
 
@@ -100,6 +101,9 @@ func (l *Layouter) HandleEvents() {
 			l.placeBlocks()
 		case cfg := <-l.config:
 			l.engine.ConfigUnsafe(cfg)
+			// the config may have moved, added or removed blocks
+			l.placeBlocks()
+			l.SetSizeHint(l.engine.GetHint())
 		}
 	}
 }
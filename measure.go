@@ -0,0 +1,161 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"code.google.com/p/freetype-go/freetype/truetype"
+)
+
+// FontDPI is the resolution draw2d renders text at, which turns font sizes
+// in points into pixels.
+const FontDPI = 92
+
+// FontMetrics describe a font at a particular size, in pixels. Ascent and
+// Descent are both measured away from the baseline, so both are positive.
+type FontMetrics struct {
+	Ascent, Descent float64
+	// The distance between the baselines of consecutive lines.
+	LineHeight float64
+}
+
+// TextMetrics describe a string drawn on one line, in pixels.
+type TextMetrics struct {
+	FontMetrics
+	// The distance from the start of the text to where following text
+	// would start.
+	Width float64
+	// Carets[i] is where the caret sits before the ith rune, and the last
+	// entry is Width.
+	Carets []float64
+}
+
+// fontScale is what truetype calls the scale for a font size: 26.6 fixed
+// point units per em.
+func fontScale(size float64) int32 {
+	return int32(size * FontDPI * 64 / 72)
+}
+
+// fixedToFloat converts 26.6 fixed point units to pixels.
+func fixedToFloat(x int32) float64 {
+	return float64(x) / 64
+}
+
+// fontFor finds the font that draws r when asking for fd, using the
+// fallbacks of Fonts if fd is a face it knows.
+func fontFor(fd draw2d.FontData, r rune) (font *truetype.Font, rfd draw2d.FontData) {
+	if face := Fonts.FaceForRune(fd, r); face != nil {
+		return face.Font, face.Data
+	}
+	return draw2d.GetFont(fd), fd
+}
+
+// MeasureFont returns the metrics of fd at size, without drawing anything.
+func MeasureFont(fd draw2d.FontData, size float64) (fm FontMetrics) {
+	font := draw2d.GetFont(fd)
+	if face := Fonts.Face(fd); face != nil {
+		font = face.Font
+	}
+	if font == nil {
+		return
+	}
+	fupe := font.FUnitsPerEm()
+	bounds := font.Bounds(fupe)
+	em := size * FontDPI / 72
+	fm.Ascent = float64(bounds.YMax) * em / float64(fupe)
+	fm.Descent = float64(-bounds.YMin) * em / float64(fupe)
+	fm.LineHeight = fm.Ascent + fm.Descent
+	return
+}
+
+// A placedGlyph is a rune of a string, with the font that draws it and
+// where its pen position is, in pixels from the start of the string.
+type placedGlyph struct {
+	Font  *truetype.Font
+	Data  draw2d.FontData
+	Index truetype.Index
+	X     float64
+}
+
+// placeGlyphs lays text out on one line, with kerning and fallback fonts,
+// returning a glyph for each rune. Runes with no font have a nil Font.
+func placeGlyphs(text string, fd draw2d.FontData, size float64) (glyphs []placedGlyph, width float64) {
+	scale := fontScale(size)
+	var prev placedGlyph
+	hasPrev := false
+	for _, r := range text {
+		g := placedGlyph{X: width}
+		g.Font, g.Data = fontFor(fd, r)
+		if g.Font == nil {
+			glyphs = append(glyphs, g)
+			hasPrev = false
+			continue
+		}
+		g.Index = g.Font.Index(r)
+		// kerning only applies between glyphs of the same font
+		if hasPrev && g.Data == prev.Data {
+			width += fixedToFloat(g.Font.Kerning(scale, prev.Index, g.Index))
+			g.X = width
+		}
+		width += fixedToFloat(g.Font.HMetric(scale, g.Index).AdvanceWidth)
+		glyphs = append(glyphs, g)
+		prev, hasPrev = g, true
+	}
+	return
+}
+
+// MeasureString returns the metrics of text drawn with fd at size, as
+// RenderString would draw it, including kerning and fallback fonts, but
+// without drawing anything.
+func MeasureString(text string, fd draw2d.FontData, size float64) (tm TextMetrics) {
+	tm.FontMetrics = MeasureFont(fd, size)
+	glyphs, width := placeGlyphs(text, fd, size)
+	measured := map[draw2d.FontData]bool{fd: true}
+	for _, g := range glyphs {
+		tm.Carets = append(tm.Carets, g.X)
+		if g.Font == nil || measured[g.Data] {
+			continue
+		}
+		// a fallback font may reach further from the baseline
+		measured[g.Data] = true
+		ffm := MeasureFont(g.Data, size)
+		if ffm.Ascent > tm.Ascent {
+			tm.Ascent = ffm.Ascent
+		}
+		if ffm.Descent > tm.Descent {
+			tm.Descent = ffm.Descent
+		}
+		tm.LineHeight = tm.Ascent + tm.Descent
+	}
+	tm.Carets = append(tm.Carets, width)
+	tm.Width = width
+	return
+}
+
+// CaretIndex returns the index of the caret position nearest to x.
+func (tm TextMetrics) CaretIndex(x float64) (i int) {
+	for j, c := range tm.Carets {
+		if c > x {
+			if j > 0 && x-tm.Carets[j-1] < c-x {
+				return j - 1
+			}
+			return j
+		}
+		i = j
+	}
+	return
+}
@@ -0,0 +1,262 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"container/list"
+	"image"
+	"sync"
+)
+
+// Buffers are rounded up to a multiple of this many pixels in each
+// dimension, so that small resizes can reuse the same allocation.
+const BufferGranularity = 64
+
+// BufferPoolSize is the number of idle buffers kept for each size class.
+const BufferPoolSize = 4
+
+type BufferStats struct {
+	// Calls to Get, and how many of them were satisfied by the pool.
+	Gets, Hits, Misses int
+	// Calls to Put, and how many buffers were thrown away because the
+	// pool for their size class was full.
+	Puts, Discards int
+	// Bytes sitting idle in the pool.
+	PooledBytes int
+
+	// Bytes held by child back-buffers, the budget for them, and how
+	// many have been evicted to stay within it.
+	CachedBytes, Budget int
+	Evictions           int
+}
+
+type sizeClass struct {
+	width, height int
+}
+
+func sizeClassFor(width, height int) sizeClass {
+	round := func(x int) int {
+		return (x + BufferGranularity - 1) / BufferGranularity * BufferGranularity
+	}
+	return sizeClass{round(width), round(height)}
+}
+
+// A BufferPool recycles RGBA buffers, keyed by size class. It is safe for
+// use from multiple goroutines.
+type BufferPool struct {
+	mu    sync.Mutex
+	idle  map[sizeClass][]*image.RGBA
+	stats BufferStats
+}
+
+func NewBufferPool() (p *BufferPool) {
+	p = new(BufferPool)
+	p.idle = map[sizeClass][]*image.RGBA{}
+	return
+}
+
+// Get returns a zeroed buffer with bounds (0, 0)-(width, height).
+func (p *BufferPool) Get(width, height int) (buf *image.RGBA) {
+	r := image.Rect(0, 0, width, height)
+	if r.Empty() {
+		return image.NewRGBA(r)
+	}
+	sc := sizeClassFor(width, height)
+
+	p.mu.Lock()
+	p.stats.Gets++
+	var full *image.RGBA
+	if bufs := p.idle[sc]; len(bufs) != 0 {
+		full = bufs[len(bufs)-1]
+		p.idle[sc] = bufs[:len(bufs)-1]
+		p.stats.Hits++
+		p.stats.PooledBytes -= len(full.Pix)
+	} else {
+		p.stats.Misses++
+	}
+	p.mu.Unlock()
+
+	if full == nil {
+		full = image.NewRGBA(image.Rect(0, 0, sc.width, sc.height))
+	} else {
+		ZeroRGBA(full.SubImage(r).(*image.RGBA))
+	}
+	buf = full.SubImage(r).(*image.RGBA)
+	return
+}
+
+// Put hands a buffer obtained from Get back to the pool. The caller must
+// not use it afterwards.
+func (p *BufferPool) Put(buf *image.RGBA) {
+	if buf == nil || buf.Stride == 0 {
+		return
+	}
+	// recover the full allocation behind the sub-image Get returned
+	width := buf.Stride / 4
+	height := cap(buf.Pix) / buf.Stride
+	sc := sizeClass{width, height}
+	if sc != sizeClassFor(width, height) {
+		// not one of ours
+		return
+	}
+	full := &image.RGBA{
+		Pix:    buf.Pix[:cap(buf.Pix)],
+		Stride: buf.Stride,
+		Rect:   image.Rect(0, 0, width, height),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.stats.Puts++
+	if len(p.idle[sc]) >= BufferPoolSize {
+		p.stats.Discards++
+		return
+	}
+	p.idle[sc] = append(p.idle[sc], full)
+	p.stats.PooledBytes += len(full.Pix)
+}
+
+func (p *BufferPool) Stats() (stats BufferStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats = p.stats
+	return
+}
+
+// A BufferCache tracks the back-buffers that Foundations keep for their
+// children, and evicts the least recently drawn ones once their total size
+// goes over budget. An evicted child is simply redrawn the next time its
+// parent composites it.
+type BufferCache struct {
+	pool *BufferPool
+
+	mu        sync.Mutex
+	lru       *list.List
+	elems     map[*Block]*list.Element
+	used      int
+	budget    int
+	evictions int
+}
+
+type cacheEntry struct {
+	block *Block
+	bytes int
+}
+
+func NewBufferCache(pool *BufferPool, budget int) (c *BufferCache) {
+	c = new(BufferCache)
+	c.pool = pool
+	c.lru = list.New()
+	c.elems = map[*Block]*list.Element{}
+	c.budget = budget
+	return
+}
+
+// SetBudget changes the number of bytes child buffers may occupy. A budget
+// of zero or less means no limit.
+func (c *BufferCache) SetBudget(budget int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+	c.evict()
+}
+
+func (c *BufferCache) Stats() (stats BufferStats) {
+	stats = c.pool.Stats()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.CachedBytes = c.used
+	stats.Budget = c.budget
+	stats.Evictions = c.evictions
+	return
+}
+
+// touch marks b's buffer as just drawn. The caller must hold b.bufferGuard.
+func (c *BufferCache) touch(b *Block) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	bytes := 0
+	if b.buffer != nil {
+		bytes += cap(b.buffer.Pix)
+	}
+	if b.effectBuffer != nil {
+		bytes += cap(b.effectBuffer.Pix)
+	}
+	if el, ok := c.elems[b]; ok {
+		ce := el.Value.(*cacheEntry)
+		c.used += bytes - ce.bytes
+		ce.bytes = bytes
+		c.lru.MoveToFront(el)
+	} else {
+		c.elems[b] = c.lru.PushFront(&cacheEntry{b, bytes})
+		c.used += bytes
+	}
+	c.evict()
+}
+
+// release gives back b's buffers, for a child that has been removed.
+func (c *BufferCache) release(b *Block) {
+	b.bufferGuard.Lock()
+	defer b.bufferGuard.Unlock()
+	c.pool.Put(b.buffer)
+	c.pool.Put(b.effectBuffer)
+	b.buffer = nil
+	b.effectBuffer = nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.elems[b]; ok {
+		c.used -= el.Value.(*cacheEntry).bytes
+		c.lru.Remove(el)
+		delete(c.elems, b)
+	}
+}
+
+// evict releases buffers from the back of the list until the budget is
+// met. Blocks whose buffers are in use, including any being drawn further
+// up the current call stack, are skipped.
+func (c *BufferCache) evict() {
+	if c.budget <= 0 {
+		return
+	}
+	for el := c.lru.Back(); el != nil && c.used > c.budget; {
+		prev := el.Prev()
+		ce := el.Value.(*cacheEntry)
+		if ce.block.bufferGuard.TryLock() {
+			c.pool.Put(ce.block.buffer)
+			c.pool.Put(ce.block.effectBuffer)
+			ce.block.buffer = nil
+			ce.block.effectBuffer = nil
+			ce.block.bufferGuard.Unlock()
+
+			c.used -= ce.bytes
+			c.lru.Remove(el)
+			delete(c.elems, ce.block)
+			c.evictions++
+		}
+		el = prev
+	}
+}
+
+// Buffers is the pool used for block back-buffers and text rendering.
+var Buffers = NewBufferPool()
+
+// BlockBuffers holds the back-buffers Foundations keep for their children.
+// By default they may use up to 64MB.
+var BlockBuffers = NewBufferCache(Buffers, 64<<20)
@@ -0,0 +1,221 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// A TextStyle is how a run of text looks. Zero values are filled in from
+// the paragraph the run is in.
+type TextStyle struct {
+	Font draw2d.FontData
+	Size float64
+	// Bold and Italic pick another face of the run's font family from
+	// Fonts. If the family has no such face, the glyphs of the one it has
+	// are thickened or slanted instead.
+	Bold, Italic bool
+	// If nil, the run is drawn in the color the layout is drawn with.
+	Color      color.Color
+	Background color.Color
+	Underline  bool
+
+	// what the glyphs need doing to them, once resolved
+	synth synthesis
+}
+
+// synthesis is how glyphs are altered to stand in for a face that a font
+// family lacks.
+type synthesis uint8
+
+const (
+	synthBold synthesis = 1 << iota
+	synthItalic
+)
+
+// A TextRun is a piece of text drawn all in one style.
+type TextRun struct {
+	Text  string
+	Style TextStyle
+}
+
+// RunsText returns the text of the runs, without their styles.
+func RunsText(runs []TextRun) string {
+	texts := make([]string, len(runs))
+	for i, run := range runs {
+		texts[i] = run.Text
+	}
+	return strings.Join(texts, "")
+}
+
+// resolve fills in s from the paragraph.
+func (p Paragraph) resolve(s TextStyle) TextStyle {
+	if s.Font.Name == "" {
+		s.Font = p.Font
+	}
+	if s.Size == 0 {
+		s.Size = p.Size
+	}
+	if s.Bold || s.Italic {
+		s.Font, s.synth = styledFont(s.Font, s.Bold, s.Italic)
+	}
+	return s
+}
+
+// styledFont finds the bold or italic face in fd's family, and says what
+// is to be synthesised when the nearest face there is falls short.
+func styledFont(fd draw2d.FontData, bold, italic bool) (sfd draw2d.FontData, synth synthesis) {
+	sfd = fd
+	weight, slanted := WeightNormal, false
+	if face := Fonts.Face(fd); face != nil {
+		weight, slanted = face.Weight, face.Italic
+		want := weight
+		if bold {
+			want = WeightBold
+		}
+		if sf := Fonts.Resolve(face.Family, want, italic || face.Italic); sf != nil {
+			sfd, weight, slanted = sf.Data, sf.Weight, sf.Italic
+		}
+	}
+	if bold && weight < WeightBold {
+		synth |= synthBold
+	}
+	if italic && !slanted {
+		synth |= synthItalic
+	}
+	return
+}
+
+// ParseMarkup turns text with markup into styled runs. The markup is
+//
+//	<b>bold</b>
+//	<i>italic</i>
+//	<u>underlined</u>
+//	<color #rrggbb>colored</color>
+//	<bg #rrggbb>highlighted</bg>
+//	<size 18>resized</size>
+//
+// where colors are anything ParseColor accepts. Bold and italic use the
+// font family's own faces where Fonts has them, and otherwise thicken or
+// slant the regular glyphs. Tags may be nested, but must be closed in
+// order. Write "&lt;", "&gt;" and "&amp;" for "<", ">" and "&".
+func ParseMarkup(text string) (runs []TextRun, err error) {
+	type open struct {
+		tag   string
+		style TextStyle
+	}
+	var stack []open
+	var style TextStyle
+	var buf []byte
+
+	flush := func() {
+		if len(buf) != 0 {
+			runs = append(runs, TextRun{string(buf), style})
+			buf = nil
+		}
+	}
+
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '&':
+			end := strings.Index(text[i:], ";")
+			if end == -1 {
+				err = fmt.Errorf("markup: unterminated entity at %d", i)
+				return
+			}
+			switch text[i : i+end+1] {
+			case "&lt;":
+				buf = append(buf, '<')
+			case "&gt;":
+				buf = append(buf, '>')
+			case "&amp;":
+				buf = append(buf, '&')
+			default:
+				err = fmt.Errorf("markup: unknown entity %q", text[i:i+end+1])
+				return
+			}
+			i += end + 1
+		case '<':
+			end := strings.Index(text[i:], ">")
+			if end == -1 {
+				err = fmt.Errorf("markup: unterminated tag at %d", i)
+				return
+			}
+			tag := strings.TrimSpace(text[i+1 : i+end])
+			i += end + 1
+			flush()
+
+			if strings.HasPrefix(tag, "/") {
+				name := tag[1:]
+				if len(stack) == 0 || stack[len(stack)-1].tag != name {
+					err = fmt.Errorf("markup: unexpected </%s>", name)
+					return
+				}
+				style = stack[len(stack)-1].style
+				stack = stack[:len(stack)-1]
+				break
+			}
+
+			name, arg := tag, ""
+			if sp := strings.IndexAny(tag, " \t"); sp != -1 {
+				name, arg = tag[:sp], strings.TrimSpace(tag[sp+1:])
+			}
+			stack = append(stack, open{name, style})
+			switch name {
+			case "b":
+				style.Bold = true
+			case "i":
+				style.Italic = true
+			case "u":
+				style.Underline = true
+			case "color", "bg":
+				var c color.Color
+				c, err = ParseColor(arg)
+				if err != nil {
+					err = fmt.Errorf("markup: <%s>: %v", tag, err)
+					return
+				}
+				if name == "color" {
+					style.Color = c
+				} else {
+					style.Background = c
+				}
+			case "size":
+				style.Size, err = strconv.ParseFloat(arg, 64)
+				if err != nil || style.Size <= 0 {
+					err = fmt.Errorf("markup: <%s>: bad size", tag)
+					return
+				}
+			default:
+				err = fmt.Errorf("markup: unknown tag <%s>", name)
+				return
+			}
+		default:
+			buf = append(buf, text[i])
+			i++
+		}
+	}
+	flush()
+	if len(stack) != 0 {
+		err = fmt.Errorf("markup: <%s> is not closed", stack[len(stack)-1].tag)
+	}
+	return
+}
@@ -0,0 +1,128 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	tests := []struct {
+		markup string
+		runs   []TextRun
+	}{
+		{"", nil},
+		{"plain", []TextRun{{"plain", TextStyle{}}}},
+		{"a <b>bold</b> word", []TextRun{
+			{"a ", TextStyle{}},
+			{"bold", TextStyle{Bold: true}},
+			{" word", TextStyle{}},
+		}},
+		{"<b>x<i>y</i></b>z", []TextRun{
+			{"x", TextStyle{Bold: true}},
+			{"y", TextStyle{Bold: true, Italic: true}},
+			{"z", TextStyle{}},
+		}},
+		{"<u><color #f00>red</color></u>", []TextRun{
+			{"red", TextStyle{Color: red, Underline: true}},
+		}},
+		{"<bg #ff0000>hi</bg>", []TextRun{
+			{"hi", TextStyle{Background: red}},
+		}},
+		{"< size 18 >big</size>", []TextRun{
+			{"big", TextStyle{Size: 18}},
+		}},
+		{"1 &lt; 2 &amp;&amp; 3 &gt; 2", []TextRun{
+			{"1 < 2 && 3 > 2", TextStyle{}},
+		}},
+		{"<b></b>", nil},
+	}
+	for _, test := range tests {
+		runs, err := ParseMarkup(test.markup)
+		if err != nil {
+			t.Errorf("%q: %v", test.markup, err)
+			continue
+		}
+		if !reflect.DeepEqual(runs, test.runs) {
+			t.Errorf("%q: runs %v, want %v", test.markup, runs, test.runs)
+		}
+	}
+}
+
+func TestParseMarkupErrors(t *testing.T) {
+	tests := []struct {
+		markup string
+		err    string
+	}{
+		{"a & b", "unterminated entity"},
+		{"&nbsp;", "unknown entity"},
+		{"a <b", "unterminated tag"},
+		{"</b>", "unexpected </b>"},
+		{"<b><i>x</b></i>", "unexpected </b>"},
+		{"<b>x", "<b> is not closed"},
+		{"<blink>x</blink>", "unknown tag <blink>"},
+		{"<color red>x</color>", "<color red>"},
+		{"<color>x</color>", "<color>"},
+		{"<size big>x</size>", "bad size"},
+		{"<size -2>x</size>", "bad size"},
+	}
+	for _, test := range tests {
+		_, err := ParseMarkup(test.markup)
+		if err == nil {
+			t.Errorf("%q: no error, want one about %q", test.markup, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error %q, want one about %q", test.markup, err, test.err)
+		}
+	}
+}
+
+func TestRunsText(t *testing.T) {
+	runs, err := ParseMarkup("a<b>b</b>c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := RunsText(runs); got != "abc" {
+		t.Errorf("RunsText = %q, want %q", got, "abc")
+	}
+}
+
+func TestStyledFontSynthesis(t *testing.T) {
+	// a font Fonts doesn't know has no faces to pick from
+	fd := draw2d.FontData{Name: "nosuchfont"}
+	tests := []struct {
+		bold, italic bool
+		synth        synthesis
+	}{
+		{false, false, 0},
+		{true, false, synthBold},
+		{false, true, synthItalic},
+		{true, true, synthBold | synthItalic},
+	}
+	for _, test := range tests {
+		sfd, synth := styledFont(fd, test.bold, test.italic)
+		if sfd != fd || synth != test.synth {
+			t.Errorf("bold %v, italic %v: %v, %v, want %v, %v", test.bold, test.italic, sfd, synth, fd, test.synth)
+		}
+	}
+}
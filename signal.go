@@ -0,0 +1,164 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"sync"
+)
+
+// A Delivery says what a Signal does when a listener hasn't taken the
+// values it was already sent.
+type Delivery int
+
+const (
+	// Drop the new value.
+	DeliverDrop Delivery = iota
+	// Wait for the listener to take it. The widget emitting the signal
+	// waits too, so listeners must keep up.
+	DeliverBlock
+	// Drop the oldest value the listener hasn't taken, so that it always
+	// gets the latest one.
+	DeliverLatest
+)
+
+// A Signal is how a widget tells the rest of the app that something
+// happened, such as a button being clicked. The zero value is ready to use,
+// and it is safe for use from multiple goroutines.
+type Signal[T any] struct {
+	mu        sync.Mutex
+	listeners map[*Listener[T]]bool
+	closed    bool
+}
+
+// A Listener gets the values emitted by a Signal on C, until it is stopped
+// or the signal is closed, when C is closed.
+type Listener[T any] struct {
+	C <-chan T
+
+	signal   *Signal[T]
+	delivery Delivery
+
+	// mu is held while sending on ch, and when closing it
+	mu      sync.Mutex
+	ch      chan T
+	stopped chan bool
+	stop    sync.Once
+}
+
+// Listen returns a new listener, whose channel holds buffer values not yet
+// taken. DeliverLatest listeners always hold at least one.
+func (s *Signal[T]) Listen(delivery Delivery, buffer int) (l *Listener[T]) {
+	if delivery == DeliverLatest && buffer < 1 {
+		buffer = 1
+	}
+	l = &Listener[T]{
+		signal:   s,
+		delivery: delivery,
+		ch:       make(chan T, buffer),
+		stopped:  make(chan bool),
+	}
+	l.C = l.ch
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		l.close()
+		return
+	}
+	if s.listeners == nil {
+		s.listeners = map[*Listener[T]]bool{}
+	}
+	s.listeners[l] = true
+	return
+}
+
+// Emit sends v to every listener, according to its Delivery.
+func (s *Signal[T]) Emit(v T) {
+	s.mu.Lock()
+	listeners := make([]*Listener[T], 0, len(s.listeners))
+	for l := range s.listeners {
+		listeners = append(listeners, l)
+	}
+	s.mu.Unlock()
+	for _, l := range listeners {
+		l.send(v)
+	}
+}
+
+// Close stops every listener, and any that listen later. A widget can't
+// tell when it is thrown away, so its signals are only closed by whatever
+// throws it away knowingly, such as a Screen removing a node, or a Radio
+// replacing its buttons. Otherwise, listeners must call Stop when they are
+// done.
+func (s *Signal[T]) Close() {
+	s.mu.Lock()
+	s.closed = true
+	listeners := s.listeners
+	s.listeners = nil
+	s.mu.Unlock()
+	for l := range listeners {
+		l.close()
+	}
+}
+
+// Stop stops sending to l and closes its channel. Values already on the
+// channel can still be taken.
+func (l *Listener[T]) Stop() {
+	l.signal.mu.Lock()
+	delete(l.signal.listeners, l)
+	l.signal.mu.Unlock()
+	l.close()
+}
+
+func (l *Listener[T]) close() {
+	l.stop.Do(func() {
+		// a blocked send gives up here, and lets go of l.mu
+		close(l.stopped)
+		l.mu.Lock()
+		close(l.ch)
+		l.mu.Unlock()
+	})
+}
+
+func (l *Listener[T]) send(v T) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.stopped:
+		return
+	default:
+	}
+	switch l.delivery {
+	case DeliverDrop:
+		select {
+		case l.ch <- v:
+		default:
+		}
+	case DeliverBlock:
+		select {
+		case l.ch <- v:
+		case <-l.stopped:
+		}
+	case DeliverLatest:
+		for {
+			select {
+			case l.ch <- v:
+				return
+			case <-l.ch:
+			}
+		}
+	}
+}
@@ -0,0 +1,494 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A StyleState is a set of the transient conditions a block can be in,
+// which style sheet selectors can pick out.
+type StyleState uint8
+
+const (
+	StateDisabled StyleState = 1 << iota
+	StatePressed
+	StateHover
+	StateFocused
+	// Pressed, with the pointer dragged off the block, where letting go
+	// does nothing.
+	StatePressedOutside
+)
+
+// in order of precedence, for the theme's state-specific keys
+var styleStates = []struct {
+	state StyleState
+	name  string
+}{
+	{StateDisabled, "disabled"},
+	{StatePressed, "pressed"},
+	{StatePressedOutside, "pressed-outside"},
+	{StateHover, "hover"},
+	{StateFocused, "focused"},
+}
+
+// A Selector picks out blocks by their Type, Name and State. It is written
+// as any of
+//
+//	Type#name:state:state
+//	Type:state
+//	#name
+//	*
+//
+// where every part is optional, and "*" matches everything.
+type Selector struct {
+	Type   string
+	Name   string
+	States StyleState
+}
+
+func ParseSelector(s string) (sel Selector, err error) {
+	s = strings.TrimSpace(s)
+	if s == "*" || s == "" {
+		return
+	}
+	parts := strings.Split(s, ":")
+	for _, state := range parts[1:] {
+		found := false
+		for _, ss := range styleStates {
+			if ss.name == state {
+				sel.States |= ss.state
+				found = true
+			}
+		}
+		if !found {
+			err = fmt.Errorf("selector %q: unknown state %q", s, state)
+			return
+		}
+	}
+	sel.Type = parts[0]
+	if i := strings.Index(sel.Type, "#"); i != -1 {
+		sel.Name = sel.Type[i+1:]
+		sel.Type = sel.Type[:i]
+	}
+	if sel.Type == "*" {
+		sel.Type = ""
+	}
+	return
+}
+
+func (sel Selector) Matches(b *Block) bool {
+	if sel.Type != "" && sel.Type != b.Type {
+		return false
+	}
+	if sel.Name != "" && sel.Name != b.Name {
+		return false
+	}
+	return b.State&sel.States == sel.States
+}
+
+// Specificity orders selectors the way CSS does: names count for more than
+// states, and states for more than types.
+func (sel Selector) Specificity() (spec int) {
+	if sel.Name != "" {
+		spec += 100
+	}
+	for s := sel.States; s != 0; s &= s - 1 {
+		spec += 10
+	}
+	if sel.Type != "" {
+		spec += 1
+	}
+	return
+}
+
+// A StyleFont names a font in a style sheet. If Name is a family known to
+// Fonts, the face nearest to Weight (400 if zero) and Style is used.
+// Otherwise, Family is one of "sans", "serif" or "mono", and Style is one
+// of "normal", "bold", "italic" or "bolditalic", naming a font registered
+// directly with draw2d.
+type StyleFont struct {
+	Name, Family, Style string
+	Weight              int
+}
+
+func (sf StyleFont) FontData() (fd draw2d.FontData, err error) {
+	fd.Name = sf.Name
+	switch sf.Family {
+	case "", "sans":
+		fd.Family = draw2d.FontFamilySans
+	case "serif":
+		fd.Family = draw2d.FontFamilySerif
+	case "mono":
+		fd.Family = draw2d.FontFamilyMono
+	default:
+		err = fmt.Errorf("unknown font family %q", sf.Family)
+		return
+	}
+	switch sf.Style {
+	case "", "normal":
+		fd.Style = draw2d.FontStyleNormal
+	case "bold":
+		fd.Style = draw2d.FontStyleBold
+	case "italic":
+		fd.Style = draw2d.FontStyleItalic
+	case "bolditalic":
+		fd.Style = draw2d.FontStyleBold | draw2d.FontStyleItalic
+	default:
+		err = fmt.Errorf("unknown font style %q", sf.Style)
+		return
+	}
+
+	weight := sf.Weight
+	if weight == 0 {
+		weight = WeightNormal
+		if fd.Style&draw2d.FontStyleBold != 0 {
+			weight = WeightBold
+		}
+	}
+	italic := fd.Style&draw2d.FontStyleItalic != 0
+	if face := Fonts.Resolve(sf.Name, weight, italic); face != nil {
+		fd = face.Data
+	}
+	return
+}
+
+type StyleRule struct {
+	Selector string
+	// Colors are written "#rgb", "#rrggbb", "#rrggbbaa", or one of
+	// "black", "white" and "transparent".
+	Colors  map[string]string
+	Metrics map[string]float64
+	Fonts   map[string]StyleFont
+
+	selector Selector
+	colors   map[string]color.Color
+	fonts    map[string]draw2d.FontData
+}
+
+// A StyleSheet restyles blocks without recompiling. Its rules are applied
+// in preference to the plain values of the theme it belongs to, and, for
+// equally specific selectors, later rules win.
+//
+// Properties whose names begin with "text" cascade: if no rule matches the
+// block itself, rules matching its ancestors are tried, nearest first. They
+// are resolved when the block renders, so a change in an ancestor's state
+// does not, by itself, restyle the descendants.
+type StyleSheet struct {
+	Name string
+	// The name of a registered theme that this sheet builds upon.
+	Base  string
+	Rules []StyleRule
+}
+
+func ReadStyleSheet(r io.Reader) (ss *StyleSheet, err error) {
+	ss = new(StyleSheet)
+	dec := json.NewDecoder(r)
+	err = dec.Decode(ss)
+	if err != nil {
+		return
+	}
+	err = ss.compile()
+	return
+}
+
+func ParseStyleSheet(text string) (ss *StyleSheet, err error) {
+	r := strings.NewReader(text)
+	ss, err = ReadStyleSheet(r)
+	return
+}
+
+func LoadStyleSheet(path string) (ss *StyleSheet, err error) {
+	fin, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer fin.Close()
+	ss, err = ReadStyleSheet(fin)
+	return
+}
+
+func (ss *StyleSheet) compile() (err error) {
+	for i := range ss.Rules {
+		rule := &ss.Rules[i]
+		rule.selector, err = ParseSelector(rule.Selector)
+		if err != nil {
+			return
+		}
+		rule.colors = map[string]color.Color{}
+		for prop, cs := range rule.Colors {
+			rule.colors[prop], err = ParseColor(cs)
+			if err != nil {
+				return
+			}
+		}
+		rule.fonts = map[string]draw2d.FontData{}
+		for prop, sf := range rule.Fonts {
+			rule.fonts[prop], err = sf.FontData()
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Theme makes a theme that uses the sheet's rules, built upon the sheet's
+// Base, or on parent if the sheet has none.
+func (ss *StyleSheet) Theme(parent *Theme) (t *Theme) {
+	if base := LookupTheme(ss.Base); base != nil {
+		parent = base
+	}
+	name := ss.Name
+	if name == "" {
+		name = "stylesheet"
+	}
+	if parent == nil {
+		t = NewTheme(name)
+	} else {
+		t = parent.Derive(name)
+	}
+	t.Sheet = ss
+	return
+}
+
+// match finds the most specific rule that matches b, and for which has
+// returns true.
+func (ss *StyleSheet) match(b *Block, has func(*StyleRule) bool) (best *StyleRule) {
+	bestSpec := -1
+	for i := range ss.Rules {
+		rule := &ss.Rules[i]
+		if !rule.selector.Matches(b) || !has(rule) {
+			continue
+		}
+		if spec := rule.selector.Specificity(); spec >= bestSpec {
+			best, bestSpec = rule, spec
+		}
+	}
+	return
+}
+
+func ParseColor(s string) (c color.Color, err error) {
+	switch s {
+	case "black":
+		return color.Black, nil
+	case "white":
+		return color.White, nil
+	case "transparent":
+		return color.Transparent, nil
+	}
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 || !strings.HasPrefix(s, "#") {
+		err = fmt.Errorf("bad color %q", s)
+		return
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		err = fmt.Errorf("bad color %q", s)
+		return
+	}
+	nc := color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}
+	c = color.RGBAModel.Convert(nc)
+	return
+}
+
+// style resolution
+
+func styleCascades(prop string) bool {
+	return strings.HasPrefix(prop, "text")
+}
+
+// styleRule finds the rule, from the style sheets of b's theme chain, that
+// gives prop to b, following the cascade if prop allows it.
+func (b *Block) styleRule(prop string, has func(*StyleRule) bool) (rule *StyleRule) {
+	for blk := b; blk != nil; {
+		for t := b.Theme; t != nil; t = t.Parent {
+			if t.Sheet == nil {
+				continue
+			}
+			if rule = t.Sheet.match(blk, has); rule != nil {
+				return
+			}
+		}
+		if !styleCascades(prop) || blk.Parent == nil {
+			break
+		}
+		blk = &blk.Parent.Block
+	}
+	return
+}
+
+// styleKeys lists the plain theme keys for prop, most specific first:
+// "Type.prop:state" for each of b's states, "Type.prop" and "prop".
+func (b *Block) styleKeys(prop string) (keys []string) {
+	if b.Type != "" {
+		for _, ss := range styleStates {
+			if b.State&ss.state != 0 {
+				keys = append(keys, b.Type+"."+prop+":"+ss.name)
+			}
+		}
+		keys = append(keys, b.Type+"."+prop)
+	}
+	keys = append(keys, prop)
+	return
+}
+
+// StyleColor resolves a color property for b, from the style sheets and
+// plain colors of its theme. It is black if nothing provides it.
+func (b *Block) StyleColor(prop string) color.Color {
+	rule := b.styleRule(prop, func(r *StyleRule) bool {
+		_, ok := r.colors[prop]
+		return ok
+	})
+	if rule != nil {
+		return rule.colors[prop]
+	}
+	for _, key := range b.styleKeys(prop) {
+		if c, ok := b.Theme.color(key); ok {
+			return c
+		}
+	}
+	return color.Black
+}
+
+// StyleMetric resolves a metric property for b, like StyleColor. It is 0
+// if nothing provides it.
+func (b *Block) StyleMetric(prop string) float64 {
+	rule := b.styleRule(prop, func(r *StyleRule) bool {
+		_, ok := r.Metrics[prop]
+		return ok
+	})
+	if rule != nil {
+		return rule.Metrics[prop]
+	}
+	for _, key := range b.styleKeys(prop) {
+		if m, ok := b.Theme.metric(key); ok {
+			return m
+		}
+	}
+	return 0
+}
+
+// StyleFont resolves a font property for b, like StyleColor. It is
+// DefaultFontData if nothing provides it.
+func (b *Block) StyleFont(prop string) draw2d.FontData {
+	rule := b.styleRule(prop, func(r *StyleRule) bool {
+		_, ok := r.fonts[prop]
+		return ok
+	})
+	if rule != nil {
+		return rule.fonts[prop]
+	}
+	for _, key := range b.styleKeys(prop) {
+		if fd, ok := b.Theme.font(key); ok {
+			return fd
+		}
+	}
+	return DefaultFontData
+}
+
+// SetState turns the given states on or off, invalidating the block if
+// that changes anything. Call it from the block's own goroutine.
+func (b *Block) SetState(states StyleState, on bool) {
+	ns := b.State &^ states
+	if on {
+		ns |= states
+	}
+	if ns == b.State {
+		return
+	}
+	if states&StateFocused != 0 {
+		trackFocus(b, on)
+	}
+	b.State = ns
+	b.setAccessState(ns)
+	b.Invalidate()
+}
+
+// WatchStyleSheet loads the style sheet at path, and gives b the theme it
+// makes, built on base. Whenever the file changes, it is loaded again and
+// the new theme replaces the old one. Errors in later versions of the file
+// are logged, and leave the current theme in place.
+func WatchStyleSheet(b *Block, path string, base *Theme) (stop func(), err error) {
+	ss, err := LoadStyleSheet(path)
+	if err != nil {
+		return
+	}
+	b.SetTheme(ss.Theme(base))
+	stop = WatchFile(path, WatchInterval, func() {
+		ss, err := LoadStyleSheet(path)
+		if err != nil {
+			log.Print("style sheet ", path, ": ", err)
+			return
+		}
+		b.SetTheme(ss.Theme(base))
+	})
+	return
+}
+
+// WatchInterval is how often WatchFile checks for changes.
+var WatchInterval = 500 * time.Millisecond
+
+// WatchFile calls changed, from its own goroutine, each time the
+// modification time or size of the file at path changes. Call stop to
+// quit watching.
+func WatchFile(path string, interval time.Duration, changed func()) (stop func()) {
+	done := make(chan bool)
+	stat := func() (mod time.Time, size int64) {
+		if fi, err := os.Stat(path); err == nil {
+			mod, size = fi.ModTime(), fi.Size()
+		}
+		return
+	}
+	go func() {
+		mod, size := stat()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				nmod, nsize := stat()
+				if nmod.Equal(mod) && nsize == size {
+					break
+				}
+				mod, size = nmod, nsize
+				changed()
+			}
+		}
+	}()
+	stop = func() {
+		close(done)
+	}
+	return
+}
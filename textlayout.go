@@ -0,0 +1,652 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"github.com/skelterjohn/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+type WrapMode int
+
+const (
+	// Lines only break at newlines.
+	WrapNone WrapMode = iota
+	// Lines break between words, and within words too long for a line.
+	WrapWord
+	// Lines break between any two characters.
+	WrapChar
+)
+
+// Alignment is in the direction of the paragraph: in right to left
+// paragraphs, AlignLeft puts lines against the right edge, where they
+// start, and AlignRight against the left.
+type Alignment int
+
+const (
+	AlignLeft Alignment = iota
+	AlignCenter
+	AlignRight
+	// Spread the words of every line but the last of each paragraph out
+	// to the full width.
+	AlignJustify
+)
+
+// Ellipsis is put at the end of truncated lines.
+const Ellipsis = "…"
+
+// A Paragraph is text to be laid out, and how to lay it out. Sizes are in
+// pixels.
+type Paragraph struct {
+	Text string
+	// If not nil, the paragraph is made of these runs, and Text is
+	// ignored. Font and Size are the defaults for runs that don't set
+	// their own.
+	Runs []TextRun
+	Font draw2d.FontData
+	Size float64
+
+	// The width lines wrap at and are aligned within. If zero, lines only
+	// break at newlines, and are aligned within the widest of them.
+	Width     float64
+	Wrap      WrapMode
+	Align     Alignment
+	Direction Direction
+	// The distance between the tops of consecutive lines, as a multiple
+	// of their height. Zero means 1.
+	LineSpacing float64
+	// If not zero, lines past MaxLines are dropped, and the last one kept
+	// ends in an Ellipsis.
+	MaxLines int
+	// If true, lines wider than Width are cut short with an Ellipsis
+	// instead of running over.
+	Ellipsis bool
+}
+
+// Scaled returns the paragraph with every size multiplied by scale, for
+// laying out at a block's physical resolution.
+func (p Paragraph) Scaled(scale float64) Paragraph {
+	p.Size *= scale
+	p.Width *= scale
+	if p.Runs != nil {
+		runs := make([]TextRun, len(p.Runs))
+		for i, run := range p.Runs {
+			run.Style.Size *= scale
+			runs[i] = run
+		}
+		p.Runs = runs
+	}
+	return p
+}
+
+// A TextLine is one line of a TextLayout.
+type TextLine struct {
+	Text string
+	// The pieces of Text, with their styles filled in from the paragraph.
+	Runs []TextRun
+	// Where, in the paragraph's text, the line came from. Offsets are in
+	// runes. Lines that were cut short still report the full range.
+	Start, End int
+	// The left end of the line's baseline.
+	Origin geom.Coord
+	Width  float64
+	// How far the line reaches above and below its baseline.
+	Ascent, Descent float64
+	// The embedding level of the paragraph the line is in, and the level
+	// of each rune of Text. Odd levels run right to left.
+	Level  int
+	Levels []int
+	// Extents[i] is where the ith rune of Text is shown, from its left
+	// edge to its right edge, relative to Origin. Runes are shown in
+	// visual order, so extents of neighboring runes need not touch.
+	Extents [][2]float64
+	// Carets[i] is the caret position, relative to Origin, before the
+	// ith rune of Text, which is on the side of the rune its direction
+	// starts from. The last entry is the end of the line.
+	Carets []float64
+}
+
+// A TextLayout is a Paragraph broken into lines and positioned, ready to
+// be drawn.
+type TextLayout struct {
+	Paragraph
+	// The metrics of the paragraph's font.
+	FontMetrics
+	Lines []TextLine
+	// The size of the box the lines are laid out in.
+	Width, Height float64
+}
+
+type lineBreak struct {
+	start, end int
+	// if the line ends a paragraph, it is not justified
+	last bool
+}
+
+// styledText is a paragraph's text as runes, each with a style.
+type styledText struct {
+	runes []rune
+	// index into styles, for each rune
+	style  []int
+	styles []TextStyle
+}
+
+func (p Paragraph) styledText() (st styledText) {
+	runs := p.Runs
+	if runs == nil {
+		runs = []TextRun{{Text: p.Text}}
+	}
+	for _, run := range runs {
+		si := len(st.styles)
+		st.styles = append(st.styles, p.resolve(run.Style))
+		for _, r := range run.Text {
+			st.runes = append(st.runes, r)
+			st.style = append(st.style, si)
+		}
+	}
+	if len(st.styles) == 0 {
+		st.styles = append(st.styles, p.resolve(TextStyle{}))
+	}
+	return
+}
+
+// slice returns the runes from start to end, sharing styles.
+func (st styledText) slice(start, end int) (sub styledText) {
+	sub.runes = append([]rune{}, st.runes[start:end]...)
+	sub.style = append([]int{}, st.style[start:end]...)
+	sub.styles = st.styles
+	return
+}
+
+// spans calls fn for each stretch of runes with the same style.
+func (st styledText) spans(fn func(start, end int, s TextStyle)) {
+	for i := 0; i < len(st.runes); {
+		j := i
+		for j < len(st.runes) && st.style[j] == st.style[i] {
+			j++
+		}
+		fn(i, j, st.styles[st.style[i]])
+		i = j
+	}
+}
+
+// carets measures the runes on one line, with kerning within each span.
+func (st styledText) carets() (carets []float64) {
+	carets = []float64{0}
+	x := 0.0
+	st.spans(func(start, end int, s TextStyle) {
+		tm := MeasureString(string(st.runes[start:end]), s.Font, s.Size)
+		for _, c := range tm.Carets[1:] {
+			carets = append(carets, x+c)
+		}
+		x += tm.Width
+	})
+	return
+}
+
+// extents measures the runes on one line, shown in visual order for the
+// given levels. Kerning applies within each stretch of runes that has one
+// style and goes one way.
+func (st styledText) extents(levels []int) (extents [][2]float64, width float64) {
+	extents = make([][2]float64, len(st.runes))
+	order := VisualOrder(levels)
+	for v := 0; v < len(order); {
+		first := order[v]
+		step := 1
+		if levels[first]%2 == 1 {
+			step = -1
+		}
+		w := v + 1
+		for w < len(order) && order[w] == order[w-1]+step &&
+			levels[order[w]] == levels[first] && st.style[order[w]] == st.style[first] {
+			w++
+		}
+		start, end := first, order[w-1]+1
+		if step == -1 {
+			start, end = order[w-1], first+1
+		}
+		runes := st.runes[start:end]
+		if step == -1 {
+			runes = make([]rune, end-start)
+			for i, r := range st.runes[start:end] {
+				runes[i] = MirrorRune(r)
+			}
+		}
+		s := st.styles[st.style[first]]
+		tm := MeasureString(string(runes), s.Font, s.Size)
+		for i := start; i < end; i++ {
+			left, right := tm.Carets[i-start], tm.Carets[i-start+1]
+			if step == -1 {
+				left, right = tm.Width-right, tm.Width-left
+			}
+			extents[i] = [2]float64{width + left, width + right}
+		}
+		width += tm.Width
+		v = w
+	}
+	return
+}
+
+func (st styledText) runs() (runs []TextRun) {
+	st.spans(func(start, end int, s TextStyle) {
+		runs = append(runs, TextRun{string(st.runes[start:end]), s})
+	})
+	return
+}
+
+// metrics finds how far the runes reach from the baseline. With no runes,
+// it is the metrics of the style given.
+func (st styledText) metrics(empty int) (fm FontMetrics) {
+	if len(st.runes) == 0 {
+		s := st.styles[empty]
+		return MeasureFont(s.Font, s.Size)
+	}
+	st.spans(func(start, end int, s TextStyle) {
+		tm := MeasureString(string(st.runes[start:end]), s.Font, s.Size)
+		fm.Ascent = math.Max(fm.Ascent, tm.Ascent)
+		fm.Descent = math.Max(fm.Descent, tm.Descent)
+	})
+	fm.LineHeight = fm.Ascent + fm.Descent
+	return
+}
+
+// LayoutText breaks p into lines, and places them.
+func LayoutText(p Paragraph) (tl *TextLayout) {
+	tl = &TextLayout{Paragraph: p}
+	tl.FontMetrics = MeasureFont(p.Font, p.Size)
+	spacing := p.LineSpacing
+	if spacing == 0 {
+		spacing = 1
+	}
+
+	st := p.styledText()
+	breaks := breakLines(st.runes, st.carets(), p)
+	truncated := false
+	if p.MaxLines > 0 && len(breaks) > p.MaxLines {
+		breaks = breaks[:p.MaxLines]
+		breaks[len(breaks)-1].last = true
+		truncated = true
+	}
+
+	top := 0.0
+	for i, lb := range breaks {
+		line := TextLine{
+			Start: lb.start,
+			End:   lb.end,
+		}
+		end := lb.end
+		if p.Wrap != WrapNone && !lb.last {
+			// trailing spaces hang past the edge
+			for end > lb.start && unicode.IsSpace(st.runes[end-1]) {
+				end--
+			}
+		}
+		lt := st.slice(lb.start, end)
+		ellipsis := truncated && i == len(breaks)-1
+		if p.Ellipsis && p.Width > 0 && lt.carets()[len(lt.runes)] > p.Width {
+			ellipsis = true
+		}
+		if ellipsis {
+			lt = truncate(lt, p.Width)
+		}
+		line.Text = string(lt.runes)
+		line.Runs = lt.runs()
+		line.Level = paragraphLevel(st.runes, lb.start, p.Direction)
+		dir := DirLTR
+		if line.Level%2 == 1 {
+			dir = DirRTL
+		}
+		line.Levels = BidiLevels(lt.runes, dir)
+		line.Extents, line.Width = lt.extents(line.Levels)
+		if p.Align == AlignJustify && !lb.last && !ellipsis && p.Width > 0 {
+			line.Extents, line.Width = justify(lt.runes, line.Extents, line.Levels, p.Width)
+		}
+		line.Carets = make([]float64, len(lt.runes)+1)
+		for j, ext := range line.Extents {
+			line.Carets[j] = ext[line.Levels[j]%2]
+		}
+		if line.Level%2 == 0 {
+			line.Carets[len(lt.runes)] = line.Width
+		}
+
+		empty := 0
+		if lb.start < len(st.style) {
+			empty = st.style[lb.start]
+		} else if lb.start > 0 {
+			empty = st.style[lb.start-1]
+		}
+		fm := lt.metrics(empty)
+		line.Ascent, line.Descent = fm.Ascent, fm.Descent
+		line.Origin.Y = top + fm.Ascent
+		tl.Height = top + fm.LineHeight
+		top += fm.LineHeight * spacing
+
+		tl.Lines = append(tl.Lines, line)
+	}
+
+	tl.Width = p.Width
+	if tl.Width == 0 {
+		for _, line := range tl.Lines {
+			tl.Width = math.Max(tl.Width, line.Width)
+		}
+	}
+	for i := range tl.Lines {
+		line := &tl.Lines[i]
+		align := p.Align
+		if line.Level%2 == 1 {
+			switch align {
+			case AlignLeft:
+				align = AlignRight
+			case AlignRight:
+				align = AlignLeft
+			}
+		}
+		switch align {
+		case AlignCenter:
+			line.Origin.X = (tl.Width - line.Width) / 2
+		case AlignRight:
+			line.Origin.X = tl.Width - line.Width
+		}
+	}
+	return
+}
+
+// paragraphLevel finds the level of the paragraph, between newlines, that
+// the rune at i is in.
+func paragraphLevel(runes []rune, i int, dir Direction) int {
+	start, end := i, i
+	for start > 0 && runes[start-1] != '\n' {
+		start--
+	}
+	for end < len(runes) && runes[end] != '\n' {
+		end++
+	}
+	return ParagraphLevel(runes[start:end], dir)
+}
+
+// breakLines finds where the lines of runes start and end, given the caret
+// positions of the runes all on one line.
+func breakLines(runes []rune, carets []float64, p Paragraph) (breaks []lineBreak) {
+	start := 0
+	// the latest place the line can be broken between words
+	wordBreak := -1
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' {
+			breaks = append(breaks, lineBreak{start, i, true})
+			start, wordBreak = i+1, -1
+			continue
+		}
+		if p.Wrap == WrapNone || p.Width <= 0 {
+			continue
+		}
+		if unicode.IsSpace(r) {
+			// spaces may hang over the edge, so never cause a break
+			wordBreak = i + 1
+			continue
+		}
+		if carets[i+1]-carets[start] <= p.Width || i == start {
+			continue
+		}
+		brk := i
+		if p.Wrap == WrapWord && wordBreak > start {
+			brk = wordBreak
+		}
+		breaks = append(breaks, lineBreak{start, brk, false})
+		start, wordBreak = brk, -1
+		i = brk - 1
+	}
+	breaks = append(breaks, lineBreak{start, len(runes), true})
+	return
+}
+
+// truncate shortens lt until it, followed by an Ellipsis in the style of
+// its last rune, fits in width. With no width, it only adds the Ellipsis.
+func truncate(lt styledText, width float64) (tt styledText) {
+	n := len(lt.runes)
+	for n > 0 && unicode.IsSpace(lt.runes[n-1]) {
+		n--
+	}
+	es := 0
+	if n > 0 {
+		es = lt.style[n-1]
+	}
+	withEllipsis := func(n int) (tt styledText) {
+		tt = lt.slice(0, n)
+		for _, r := range Ellipsis {
+			tt.runes = append(tt.runes, r)
+			tt.style = append(tt.style, es)
+		}
+		return
+	}
+	tt = withEllipsis(n)
+	if width <= 0 {
+		return
+	}
+	carets := lt.carets()
+	s := lt.styles[es]
+	ew := MeasureString(Ellipsis, s.Font, s.Size).Width
+	for n > 0 && carets[n]+ew > width {
+		n--
+	}
+	for n > 0 && unicode.IsSpace(lt.runes[n-1]) {
+		n--
+	}
+	tt = withEllipsis(n)
+	return
+}
+
+// justify spreads the spaces in runes, shown at extents, so that they are
+// width wide.
+func justify(runes []rune, extents [][2]float64, levels []int, width float64) (je [][2]float64, jw float64) {
+	spaces := 0
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			spaces++
+		}
+		jw = math.Max(jw, extents[i][1])
+	}
+	if spaces == 0 {
+		return extents, jw
+	}
+	extra := (width - jw) / float64(spaces)
+	je = make([][2]float64, len(extents))
+	shift := 0.0
+	for _, i := range VisualOrder(levels) {
+		je[i][0] = extents[i][0] + shift
+		if unicode.IsSpace(runes[i]) {
+			shift += extra
+		}
+		je[i][1] = extents[i][1] + shift
+	}
+	jw = width
+	return
+}
+
+// spanExtents joins the extents of runes that touch, so that backgrounds
+// and underlines have no seams between them.
+func spanExtents(extents [][2]float64) (spans [][2]float64) {
+	sorted := append([][2]float64{}, extents...)
+	sort.Sort(extentSorter(sorted))
+	for _, ext := range sorted {
+		if n := len(spans); n > 0 && ext[0] <= spans[n-1][1]+0.5 {
+			spans[n-1][1] = math.Max(spans[n-1][1], ext[1])
+			continue
+		}
+		spans = append(spans, ext)
+	}
+	return
+}
+
+type extentSorter [][2]float64
+
+func (s extentSorter) Len() int           { return len(s) }
+func (s extentSorter) Less(i, j int) bool { return s[i][0] < s[j][0] }
+func (s extentSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func fillRect(dst draw.Image, r geom.Rect, c color.Color) {
+	draw.Draw(dst, RectangleForRect(r), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// Draw draws the lines onto dst, with the layout's top-left at origin, in
+// dst's pixels. Runs without a color of their own are drawn in c.
+func (tl *TextLayout) Draw(dst draw.Image, origin geom.Coord, c color.Color) {
+	for _, line := range tl.Lines {
+		pen := origin.Plus(line.Origin)
+
+		// backgrounds first, so that they don't cover the overhang of
+		// neighboring glyphs
+		k := 0
+		for _, run := range line.Runs {
+			n := utf8.RuneCountInString(run.Text)
+			if run.Style.Background != nil {
+				for _, span := range spanExtents(line.Extents[k : k+n]) {
+					fillRect(dst, geom.Rect{
+						Min: geom.Coord{pen.X + span[0], pen.Y - line.Ascent},
+						Max: geom.Coord{pen.X + span[1], pen.Y + line.Descent},
+					}, run.Style.Background)
+				}
+			}
+			k += n
+		}
+
+		k = 0
+		for _, run := range line.Runs {
+			runes := []rune(run.Text)
+			n := len(runes)
+			rc := run.Style.Color
+			if rc == nil {
+				rc = c
+			}
+			// every glyph is drawn from its left edge, mirrored if it
+			// runs right to left
+			lefts := make([]float64, n)
+			for j := range runes {
+				lefts[j] = line.Extents[k+j][0]
+				if line.Levels[k+j]%2 == 1 {
+					runes[j] = MirrorRune(runes[j])
+				}
+			}
+			drawRunes(dst, pen, runes, lefts, run.Style.Font, run.Style.Size, run.Style.synth, rc)
+			if run.Style.Underline {
+				thickness := math.Max(1, math.Floor(run.Style.Size/14+0.5))
+				y := math.Floor(pen.Y + thickness + 0.5)
+				for _, span := range spanExtents(line.Extents[k : k+n]) {
+					fillRect(dst, geom.Rect{
+						Min: geom.Coord{pen.X + span[0], y},
+						Max: geom.Coord{pen.X + span[1], y + thickness},
+					}, rc)
+				}
+			}
+			k += n
+		}
+	}
+}
+
+// Render draws the layout onto a buffer just large enough for it.
+func (tl *TextLayout) Render(c color.Color) (buffer image.Image) {
+	buf := image.NewRGBA(image.Rectangle{
+		Max: image.Point{
+			int(math.Ceil(tl.Width)) + 1,
+			int(math.Ceil(tl.Height)) + 1,
+		},
+	})
+	tl.Draw(buf, geom.Coord{}, c)
+	buffer = buf
+	return
+}
+
+// CaretAt returns the line and the rune within its Text nearest to p,
+// which is relative to the layout's top-left.
+func (tl *TextLayout) CaretAt(p geom.Coord) (line, index int) {
+	if len(tl.Lines) == 0 {
+		return
+	}
+	for line = 0; line < len(tl.Lines)-1; line++ {
+		l := tl.Lines[line]
+		if p.Y < l.Origin.Y+l.Descent {
+			break
+		}
+	}
+	index = tl.Lines[line].CaretIndex(p.X - tl.Lines[line].Origin.X)
+	return
+}
+
+// CaretIndex returns the index of the caret nearest to x, which is
+// relative to the line's Origin.
+func (l TextLine) CaretIndex(x float64) (index int) {
+	best := math.Inf(1)
+	for i, c := range l.Carets {
+		if d := math.Abs(c - x); d < best {
+			index, best = i, d
+		}
+	}
+	return
+}
+
+// MoveVisually returns the caret that is delta places to the right of the
+// one at index, or to the left if delta is negative, as the carets are
+// shown. Only carets in stops, such as grapheme boundaries, are
+// considered. If stops is nil, every caret is.
+func (l TextLine) MoveVisually(index, delta int, stops []int) int {
+	if stops == nil {
+		stops = make([]int, len(l.Carets))
+		for i := range stops {
+			stops[i] = i
+		}
+	}
+	sorted := caretSorter{append([]int{}, stops...), l.Carets}
+	sort.Sort(sorted)
+	for i, s := range sorted.stops {
+		if s != index {
+			continue
+		}
+		i += delta
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted.stops) {
+			i = len(sorted.stops) - 1
+		}
+		return sorted.stops[i]
+	}
+	return index
+}
+
+// caretSorter sorts caret indices by position, and carets that are in the
+// same place by index.
+type caretSorter struct {
+	stops  []int
+	carets []float64
+}
+
+func (s caretSorter) Len() int { return len(s.stops) }
+func (s caretSorter) Less(i, j int) bool {
+	ci, cj := s.carets[s.stops[i]], s.carets[s.stops[j]]
+	if ci != cj {
+		return ci < cj
+	}
+	return s.stops[i] < s.stops[j]
+}
+func (s caretSorter) Swap(i, j int) { s.stops[i], s.stops[j] = s.stops[j], s.stops[i] }
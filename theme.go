@@ -0,0 +1,186 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"code.google.com/p/draw2d/draw2d"
+	"image/color"
+)
+
+// A Theme decides how blocks look: the colors, metrics and fonts their
+// paint functions use, and, optionally, the paint generators themselves.
+// Anything a theme lacks is looked up in its Parent, and paint generators
+// finally fall back to those registered with RegisterPaint.
+//
+// A theme may be read from many goroutines at once, so it should not be
+// modified once it is in use. To change the look of a running app, Derive
+// a new theme and set it with SetTheme.
+type Theme struct {
+	Name   string
+	Parent *Theme
+
+	Colors  map[string]color.Color
+	Metrics map[string]float64
+	Fonts   map[string]draw2d.FontData
+	Paints  map[string]PaintGen
+
+	// If not nil, its rules take precedence over the plain values above.
+	Sheet *StyleSheet
+}
+
+func NewTheme(name string) (t *Theme) {
+	t = new(Theme)
+	t.Name = name
+	t.Colors = map[string]color.Color{}
+	t.Metrics = map[string]float64{}
+	t.Fonts = map[string]draw2d.FontData{}
+	t.Paints = map[string]PaintGen{}
+	return
+}
+
+// Derive returns an empty theme that takes anything it isn't given from t.
+func (t *Theme) Derive(name string) (dt *Theme) {
+	dt = NewTheme(name)
+	dt.Parent = t
+	return
+}
+
+// Color returns the named color, or black if no theme in the chain has it.
+func (t *Theme) Color(name string) color.Color {
+	if c, ok := t.color(name); ok {
+		return c
+	}
+	return color.Black
+}
+
+func (t *Theme) color(name string) (c color.Color, ok bool) {
+	for ; t != nil; t = t.Parent {
+		if c, ok = t.Colors[name]; ok {
+			return
+		}
+	}
+	return
+}
+
+// Metric returns the named metric, or 0 if no theme in the chain has it.
+func (t *Theme) Metric(name string) float64 {
+	m, _ := t.metric(name)
+	return m
+}
+
+func (t *Theme) metric(name string) (m float64, ok bool) {
+	for ; t != nil; t = t.Parent {
+		if m, ok = t.Metrics[name]; ok {
+			return
+		}
+	}
+	return
+}
+
+// Font returns the named font, or DefaultFontData if no theme in the chain
+// has it.
+func (t *Theme) Font(name string) draw2d.FontData {
+	if fd, ok := t.font(name); ok {
+		return fd
+	}
+	return DefaultFontData
+}
+
+func (t *Theme) font(name string) (fd draw2d.FontData, ok bool) {
+	for ; t != nil; t = t.Parent {
+		if fd, ok = t.Fonts[name]; ok {
+			return
+		}
+	}
+	return
+}
+
+// Paint makes a PaintFunc for x, using the theme's generator for path.
+func (t *Theme) Paint(path string, x interface{}) (pf PaintFunc) {
+	for tt := t; tt != nil; tt = tt.Parent {
+		if pg, ok := tt.Paints[path]; ok {
+			pf = pg(x)
+			return
+		}
+	}
+	pf = LookupPaint(path, x)
+	return
+}
+
+// ThemedPaint returns a PaintFunc that paints x with the generator for path
+// from b's current theme, picking up a new one whenever the theme changes.
+func ThemedPaint(b *Block, path string, x interface{}) PaintFunc {
+	var theme *Theme
+	var pf PaintFunc
+	return func(gc draw2d.GraphicContext) {
+		if pf == nil || theme != b.Theme {
+			theme = b.Theme
+			pf = theme.Paint(path, x)
+		}
+		if pf != nil {
+			pf(gc)
+		}
+	}
+}
+
+var themeRegistry = map[string]*Theme{}
+
+// RegisterTheme makes t available to style sheets, by its name.
+func RegisterTheme(t *Theme) {
+	themeRegistry[t.Name] = t
+}
+
+func LookupTheme(name string) (t *Theme) {
+	t = themeRegistry[name]
+	return
+}
+
+// DefaultTheme is the theme blocks start with.
+var DefaultTheme = LightTheme
+
+// applyTheme handles a ThemeEvent on b's behalf, and reports whether b's
+// theme changed.
+func (b *Block) applyTheme(e ThemeEvent) (changed bool) {
+	if e.Inherited && b.ownTheme {
+		return
+	}
+	if !e.Inherited {
+		b.ownTheme = e.Theme != nil
+		if e.Theme == nil {
+			e.Theme = DefaultTheme
+			if b.Parent != nil {
+				e.Theme = b.Parent.Theme
+			}
+		}
+	}
+	if e.Theme == nil || e.Theme == b.Theme {
+		return
+	}
+	b.Theme = e.Theme
+	b.Invalidate()
+	changed = true
+	return
+}
+
+// SetTheme gives b, and any blocks built upon it, a theme of its own, which
+// themes set further up the tree will not override. A nil theme goes back
+// to following the parent's.
+func (b *Block) SetTheme(t *Theme) {
+	b.UserEventsIn <- ThemeEvent{
+		Theme: t,
+	}
+}
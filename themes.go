@@ -0,0 +1,152 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"image/color"
+)
+
+// LightTheme is the original look of go.uik, and the parent of the other
+// built-in themes, so it names every color, metric and font the widgets
+// use. Keys are "Type.property", optionally followed by ":state" for the
+// value to use while a block is in that state.
+var LightTheme = makeLightTheme()
+
+var DarkTheme = makeDarkTheme()
+
+var HighContrastTheme = makeHighContrastTheme()
+
+func init() {
+	RegisterTheme(LightTheme)
+	RegisterTheme(DarkTheme)
+	RegisterTheme(HighContrastTheme)
+}
+
+func gray(y uint8) color.Color {
+	return color.RGBA{y, y, y, 255}
+}
+
+func makeLightTheme() (t *Theme) {
+	t = NewTheme("light")
+
+	t.Colors["window.background"] = color.White
+
+	t.Colors["widgets.Button.background"] = gray(200)
+	t.Colors["widgets.Button.background:pressed"] = gray(50)
+	t.Colors["widgets.Button.background:disabled"] = gray(230)
+	t.Colors["widgets.Button.shortcut"] = gray(90)
+
+	t.Colors["widgets.Label.text"] = color.Black
+
+	t.Colors["widgets.Checkbox.background"] = color.RGBA{255, 0, 0, 255}
+	t.Colors["widgets.Checkbox.background:pressed"] = color.RGBA{200, 0, 0, 255}
+	t.Colors["widgets.Checkbox.background:pressed-outside"] = color.RGBA{155, 0, 0, 255}
+	t.Colors["widgets.Checkbox.check"] = color.Black
+
+	t.Colors["widgets.Radio.background"] = color.Black
+	t.Colors["widgets.Radio.selected"] = gray(110)
+
+	t.Colors["widgets.Entry.background"] = color.Transparent
+	t.Colors["widgets.Entry.background:focused"] = gray(150)
+	t.Colors["widgets.Entry.selection"] = gray(200)
+	t.Colors["widgets.Entry.text"] = color.Black
+	t.Colors["widgets.Entry.caret"] = color.Black
+
+	t.Colors["widgets.KeyGrab.background"] = color.Transparent
+	t.Colors["widgets.KeyGrab.background:focused"] = gray(150)
+	t.Colors["widgets.KeyGrab.text"] = color.Black
+
+	t.Metrics["text.size"] = 12
+	t.Metrics["widgets.Button.padding"] = 10
+	t.Metrics["widgets.Button.spacing"] = 8
+	t.Metrics["widgets.Checkbox.inset"] = 5
+	t.Metrics["widgets.Entry.margin"] = 5
+	t.Metrics["widgets.Radio.spacing"] = 2
+
+	t.Fonts["text"] = DefaultFontData
+
+	return
+}
+
+func makeDarkTheme() (t *Theme) {
+	t = LightTheme.Derive("dark")
+
+	t.Colors["window.background"] = gray(30)
+
+	t.Colors["widgets.Button.background"] = gray(70)
+	t.Colors["widgets.Button.background:pressed"] = gray(110)
+	t.Colors["widgets.Button.background:disabled"] = gray(45)
+	t.Colors["widgets.Button.shortcut"] = gray(180)
+
+	t.Colors["widgets.Label.text"] = gray(230)
+
+	t.Colors["widgets.Checkbox.background"] = gray(90)
+	t.Colors["widgets.Checkbox.background:pressed"] = gray(75)
+	t.Colors["widgets.Checkbox.background:pressed-outside"] = gray(60)
+	t.Colors["widgets.Checkbox.check"] = gray(230)
+
+	t.Colors["widgets.Radio.background"] = gray(20)
+	t.Colors["widgets.Radio.selected"] = color.RGBA{40, 90, 160, 255}
+
+	t.Colors["widgets.Entry.background:focused"] = gray(60)
+	t.Colors["widgets.Entry.selection"] = color.RGBA{50, 80, 130, 255}
+	t.Colors["widgets.Entry.text"] = gray(230)
+	t.Colors["widgets.Entry.caret"] = gray(230)
+
+	t.Colors["widgets.KeyGrab.background:focused"] = gray(60)
+	t.Colors["widgets.KeyGrab.text"] = gray(230)
+
+	return
+}
+
+func makeHighContrastTheme() (t *Theme) {
+	t = LightTheme.Derive("high-contrast")
+
+	navy := color.RGBA{0, 0, 140, 255}
+	purple := color.RGBA{128, 0, 128, 255}
+	yellow := color.RGBA{255, 255, 0, 255}
+
+	t.Colors["window.background"] = color.Black
+
+	t.Colors["widgets.Button.background"] = navy
+	t.Colors["widgets.Button.background:pressed"] = color.RGBA{0, 0, 255, 255}
+	t.Colors["widgets.Button.background:disabled"] = gray(60)
+	t.Colors["widgets.Button.shortcut"] = yellow
+
+	t.Colors["widgets.Label.text"] = color.White
+
+	t.Colors["widgets.Checkbox.background"] = color.White
+	t.Colors["widgets.Checkbox.background:pressed"] = yellow
+	t.Colors["widgets.Checkbox.background:pressed-outside"] = yellow
+	t.Colors["widgets.Checkbox.check"] = color.Black
+
+	t.Colors["widgets.Radio.background"] = color.White
+	t.Colors["widgets.Radio.selected"] = purple
+
+	t.Colors["widgets.Entry.background:focused"] = navy
+	t.Colors["widgets.Entry.selection"] = purple
+	t.Colors["widgets.Entry.text"] = color.White
+	t.Colors["widgets.Entry.caret"] = yellow
+
+	t.Colors["widgets.KeyGrab.background:focused"] = navy
+	t.Colors["widgets.KeyGrab.text"] = color.White
+
+	t.Metrics["text.size"] = 14
+	t.Metrics["widgets.Radio.spacing"] = 3
+
+	return
+}
@@ -0,0 +1,312 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"github.com/skelterjohn/geom"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Transform is an affine transformation, taking (x, y) to
+// (A*x + C*y + E, B*x + D*y + F).
+type Transform struct {
+	A, B, C, D, E, F float64
+}
+
+func IdentityTransform() Transform {
+	return Transform{A: 1, D: 1}
+}
+
+func TranslateTransform(offset geom.Coord) Transform {
+	return Transform{A: 1, D: 1, E: offset.X, F: offset.Y}
+}
+
+func ScaleTransform(sx, sy float64) Transform {
+	return Transform{A: sx, D: sy}
+}
+
+// RotateTransform rotates clockwise (on screen) by angle radians about the
+// origin.
+func RotateTransform(angle float64) Transform {
+	sin, cos := math.Sincos(angle)
+	return Transform{A: cos, B: sin, C: -sin, D: cos}
+}
+
+// Then returns the transform that applies t, followed by o.
+func (t Transform) Then(o Transform) Transform {
+	return Transform{
+		A: o.A*t.A + o.C*t.B,
+		B: o.B*t.A + o.D*t.B,
+		C: o.A*t.C + o.C*t.D,
+		D: o.B*t.C + o.D*t.D,
+		E: o.A*t.E + o.C*t.F + o.E,
+		F: o.B*t.E + o.D*t.F + o.F,
+	}
+}
+
+func (t Transform) Apply(p geom.Coord) geom.Coord {
+	return geom.Coord{
+		X: t.A*p.X + t.C*p.Y + t.E,
+		Y: t.B*p.X + t.D*p.Y + t.F,
+	}
+}
+
+// Invert returns the inverse of t. If t is degenerate, ok is false.
+func (t Transform) Invert() (inv Transform, ok bool) {
+	det := t.A*t.D - t.B*t.C
+	if det == 0 {
+		return
+	}
+	inv = Transform{
+		A: t.D / det,
+		B: -t.B / det,
+		C: -t.C / det,
+		D: t.A / det,
+	}
+	inv.E = -(inv.A*t.E + inv.C*t.F)
+	inv.F = -(inv.B*t.E + inv.D*t.F)
+	ok = true
+	return
+}
+
+// ApplyRect returns the smallest rectangle containing the image of r.
+func (t Transform) ApplyRect(r geom.Rect) (tr geom.Rect) {
+	corners := []geom.Coord{
+		t.Apply(r.Min),
+		t.Apply(geom.Coord{r.Max.X, r.Min.Y}),
+		t.Apply(r.Max),
+		t.Apply(geom.Coord{r.Min.X, r.Max.Y}),
+	}
+	tr = geom.Rect{corners[0], corners[0]}
+	for _, c := range corners[1:] {
+		tr.Min.X = math.Min(tr.Min.X, c.X)
+		tr.Min.Y = math.Min(tr.Min.Y, c.Y)
+		tr.Max.X = math.Max(tr.Max.X, c.X)
+		tr.Max.Y = math.Max(tr.Max.Y, c.Y)
+	}
+	return
+}
+
+// A Placement describes where a child sits within its Foundation, and how
+// it is composited there.
+type Placement struct {
+	// The region allotted to the block. Its size is the block's size.
+	Bounds geom.Rect
+	// 1 is fully opaque, 0 is invisible.
+	Opacity float64
+	// If not nil, the block's own coordinates are put through Transform
+	// before being offset by Bounds.Min. To rotate or scale about the
+	// block's center, translate the center to the origin first.
+	Transform *Transform
+	// How the block's colors mix with what lies beneath it.
+	Blend BlendMode
+	// Applied, in order, to the block's buffer before compositing.
+	Effects []Effect
+}
+
+func (p Placement) toParent() (t Transform) {
+	t = TranslateTransform(p.Bounds.Min)
+	if p.Transform != nil {
+		t = p.Transform.Then(t)
+	}
+	return
+}
+
+func (p Placement) local() geom.Rect {
+	return geom.Rect{Max: p.Bounds.Max.Minus(p.Bounds.Min)}
+}
+
+// Frame is the area of the parent that the block, and its effects, may
+// draw on.
+func (p Placement) Frame() geom.Rect {
+	local := p.local()
+	if m := effectsMargin(p.Effects); m != 0 {
+		local.Min = local.Min.Minus(geom.Coord{m, m})
+		local.Max = local.Max.Plus(geom.Coord{m, m})
+	}
+	return p.toParent().ApplyRect(local)
+}
+
+// ToLocal takes a point in the parent's coordinates to the block's.
+func (p Placement) ToLocal(c geom.Coord) geom.Coord {
+	if p.Transform == nil {
+		return c.Minus(p.Bounds.Min)
+	}
+	inv, ok := p.toParent().Invert()
+	if !ok {
+		return geom.Coord{math.Inf(-1), math.Inf(-1)}
+	}
+	return inv.Apply(c)
+}
+
+// Contains reports whether c, in the parent's coordinates, lands on the
+// block.
+func (p Placement) Contains(c geom.Coord) bool {
+	if p.Transform == nil {
+		return p.Bounds.ContainsCoord(c)
+	}
+	return p.local().ContainsCoord(p.ToLocal(c))
+}
+
+// RectFromLocal returns the area of the parent covered by r, given in the
+// block's coordinates.
+func (p Placement) RectFromLocal(r geom.Rect) geom.Rect {
+	if p.Transform == nil {
+		r.Translate(p.Bounds.Min)
+		return r
+	}
+	return p.toParent().ApplyRect(r)
+}
+
+// RectsToLocal returns the areas of the block covered by rs, given in the
+// parent's coordinates.
+func (p Placement) RectsToLocal(rs RectSet) (lrs RectSet) {
+	if p.Transform == nil {
+		return rs.Translate(p.Bounds.Min.Times(-1))
+	}
+	inv, ok := p.toParent().Invert()
+	if !ok {
+		return
+	}
+	for _, r := range rs {
+		lrs = append(lrs, inv.ApplyRect(r))
+	}
+	return
+}
+
+// composite draws src, the block's buffer with any effects applied, onto
+// dst according to the placement. The scales are the physical pixels per
+// unit of the block's buffer and of dst.
+func (p Placement) composite(dst draw.Image, src *image.RGBA, op draw.Op, srcScale, dstScale float64) {
+	if p.Opacity <= 0 {
+		return
+	}
+	if p.Transform == nil && srcScale == dstScale && (p.Blend == BlendNormal || op != draw.Over) {
+		sr := src.Bounds()
+		offset := image.Point{
+			int(math.Floor(p.Bounds.Min.X*dstScale + 0.5)),
+			int(math.Floor(p.Bounds.Min.Y*dstScale + 0.5)),
+		}
+		r := sr.Add(offset)
+		if p.Opacity >= 1 {
+			draw.Draw(dst, r, src, sr.Min, op)
+			return
+		}
+		mask := image.NewUniform(color.Alpha16{uint16(p.Opacity * 0xffff)})
+		draw.DrawMask(dst, r, src, sr.Min, mask, image.Point{0, 0}, op)
+		return
+	}
+	t := ScaleTransform(1/srcScale, 1/srcScale).Then(p.toParent()).Then(ScaleTransform(dstScale, dstScale))
+	drawTransformed(dst, src, t, p.Opacity, op, p.Blend)
+}
+
+// drawTransformed draws src onto dst through t, sampling bilinearly.
+func drawTransformed(dst draw.Image, src *image.RGBA, t Transform, opacity float64, op draw.Op, mode BlendMode) {
+	inv, ok := t.Invert()
+	if !ok {
+		return
+	}
+	sb := src.Bounds()
+	frame := RectangleForRect(t.ApplyRect(geom.Rect{
+		Min: geom.Coord{float64(sb.Min.X), float64(sb.Min.Y)},
+		Max: geom.Coord{float64(sb.Max.X), float64(sb.Max.Y)},
+	})).Inset(-1)
+	frame = frame.Intersect(dst.Bounds())
+
+	rgba, _ := dst.(*image.RGBA)
+	for y := frame.Min.Y; y < frame.Max.Y; y++ {
+		for x := frame.Min.X; x < frame.Max.X; x++ {
+			sp := inv.Apply(geom.Coord{float64(x) + 0.5, float64(y) + 0.5})
+			r, g, b, a := sampleBilinear(src, sp.X-0.5, sp.Y-0.5)
+			if a == 0 && op == draw.Over {
+				continue
+			}
+			r, g, b, a = r*opacity, g*opacity, b*opacity, a*opacity
+
+			var dr, dg, db, da float64
+			if op == draw.Over {
+				if rgba != nil {
+					i := rgba.PixOffset(x, y)
+					dr = float64(rgba.Pix[i+0])
+					dg = float64(rgba.Pix[i+1])
+					db = float64(rgba.Pix[i+2])
+					da = float64(rgba.Pix[i+3])
+				} else {
+					cr, cg, cb, ca := dst.At(x, y).RGBA()
+					dr, dg, db, da = float64(cr>>8), float64(cg>>8), float64(cb>>8), float64(ca>>8)
+				}
+				r, g, b, a = mode.composite(r, g, b, a, dr, dg, db, da)
+			}
+
+			c := color.RGBA{clamp8(r), clamp8(g), clamp8(b), clamp8(a)}
+			if rgba != nil {
+				i := rgba.PixOffset(x, y)
+				rgba.Pix[i+0] = c.R
+				rgba.Pix[i+1] = c.G
+				rgba.Pix[i+2] = c.B
+				rgba.Pix[i+3] = c.A
+			} else {
+				dst.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// sampleBilinear returns the premultiplied color of src at (x, y), where
+// pixel centers sit on integer coordinates. Outside of src is transparent.
+func sampleBilinear(src *image.RGBA, x, y float64) (r, g, b, a float64) {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	sb := src.Bounds()
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 2; i++ {
+			px, py := ix+i, iy+j
+			if px < sb.Min.X || py < sb.Min.Y || px >= sb.Max.X || py >= sb.Max.Y {
+				continue
+			}
+			w := (1 - fx) * (1 - fy)
+			switch {
+			case i == 1 && j == 0:
+				w = fx * (1 - fy)
+			case i == 0 && j == 1:
+				w = (1 - fx) * fy
+			case i == 1 && j == 1:
+				w = fx * fy
+			}
+			o := src.PixOffset(px, py)
+			r += w * float64(src.Pix[o+0])
+			g += w * float64(src.Pix[o+1])
+			b += w * float64(src.Pix[o+2])
+			a += w * float64(src.Pix[o+3])
+		}
+	}
+	return
+}
+
+func clamp8(x float64) uint8 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 255 {
+		return 255
+	}
+	return uint8(x + 0.5)
+}
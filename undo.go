@@ -0,0 +1,166 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"sync"
+)
+
+// A Command is a change that can be undone.
+type Command struct {
+	// Shown in the undo and redo actions' labels, such as "Typing".
+	Name string
+	Do   func()
+	Undo func()
+}
+
+// An UndoStack remembers commands, so that they can be undone and redone.
+// It is safe for use from multiple goroutines, but commands run on the
+// goroutine that calls Do, Undo or Redo.
+type UndoStack struct {
+	mu     sync.Mutex
+	done   []Command
+	undone []Command
+	limit  int
+
+	// held by changed, so that the actions end up showing the latest
+	// state, not whichever was computed last
+	changeGuard sync.Mutex
+	undo, redo  *Action
+}
+
+// NewUndoStack returns a stack that remembers at most limit commands, or
+// any number if limit is 0.
+func NewUndoStack(limit int) (s *UndoStack) {
+	s = new(UndoStack)
+	s.limit = limit
+	s.undo = NewAction("Undo", func() {
+		s.Undo()
+	})
+	s.redo = NewAction("Redo", func() {
+		s.Redo()
+	})
+	s.changed()
+	return
+}
+
+// Do runs c and pushes it. Commands that were undone can't be redone after.
+func (s *UndoStack) Do(c Command) {
+	c.Do()
+	s.Push(c)
+}
+
+// Push remembers c, which has already been done.
+func (s *UndoStack) Push(c Command) {
+	s.mu.Lock()
+	s.done = append(s.done, c)
+	if s.limit > 0 && len(s.done) > s.limit {
+		s.done = append([]Command{}, s.done[len(s.done)-s.limit:]...)
+	}
+	s.undone = nil
+	s.mu.Unlock()
+	s.changed()
+}
+
+// Undo undoes the last command done, and says if there was one.
+func (s *UndoStack) Undo() (ok bool) {
+	s.mu.Lock()
+	n := len(s.done)
+	if n == 0 {
+		s.mu.Unlock()
+		return
+	}
+	c := s.done[n-1]
+	s.done = s.done[:n-1]
+	s.undone = append(s.undone, c)
+	s.mu.Unlock()
+	c.Undo()
+	s.changed()
+	ok = true
+	return
+}
+
+// Redo does the last command undone again, and says if there was one.
+func (s *UndoStack) Redo() (ok bool) {
+	s.mu.Lock()
+	n := len(s.undone)
+	if n == 0 {
+		s.mu.Unlock()
+		return
+	}
+	c := s.undone[n-1]
+	s.undone = s.undone[:n-1]
+	s.done = append(s.done, c)
+	s.mu.Unlock()
+	c.Do()
+	s.changed()
+	ok = true
+	return
+}
+
+// Clear forgets every command.
+func (s *UndoStack) Clear() {
+	s.mu.Lock()
+	s.done, s.undone = nil, nil
+	s.mu.Unlock()
+	s.changed()
+}
+
+func (s *UndoStack) CanUndo() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.done) != 0
+}
+
+func (s *UndoStack) CanRedo() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.undone) != 0
+}
+
+// UndoAction undoes, and is enabled when there is something to undo. Its
+// label names the command, such as "Undo Typing".
+func (s *UndoStack) UndoAction() *Action {
+	return s.undo
+}
+
+// RedoAction is like UndoAction, for redoing.
+func (s *UndoStack) RedoAction() *Action {
+	return s.redo
+}
+
+// changed updates the undo and redo actions.
+func (s *UndoStack) changed() {
+	s.changeGuard.Lock()
+	defer s.changeGuard.Unlock()
+	s.mu.Lock()
+	undo, redo := "Undo", "Redo"
+	if n := len(s.done); n != 0 && s.done[n-1].Name != "" {
+		undo += " " + s.done[n-1].Name
+	}
+	if n := len(s.undone); n != 0 && s.undone[n-1].Name != "" {
+		redo += " " + s.undone[n-1].Name
+	}
+	canUndo, canRedo := len(s.done) != 0, len(s.undone) != 0
+	s.mu.Unlock()
+	s.undo.change(func(st *ActionState) {
+		st.Label, st.Enabled = undo, canUndo
+	})
+	s.redo.change(func(st *ActionState) {
+		st.Label, st.Enabled = redo, canRedo
+	})
+}
@@ -0,0 +1,44 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package uik
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestUndoActionsFollowStack(t *testing.T) {
+	s := NewUndoStack(0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.Push(Command{Name: "Typing", Do: func() {}, Undo: func() {}})
+				s.Undo()
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := s.UndoAction().State().Enabled, s.CanUndo(); got != want {
+		t.Errorf("undo enabled is %v, want %v", got, want)
+	}
+	if got, want := s.RedoAction().State().Enabled, s.CanRedo(); got != want {
+		t.Errorf("redo enabled is %v, want %v", got, want)
+	}
+}
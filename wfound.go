@@ -29,6 +29,10 @@ import (
 // too long, they'll just have to wait for the next frame.
 const FrameDelay = 16 * time.Millisecond
 
+// DefaultScale is the number of physical pixels per unit that new windows
+// start with. Set it to 2 for high-density displays.
+var DefaultScale = 1.0
+
 // A foundation that wraps a wde.Window
 type WindowFoundation struct {
 	Foundation
@@ -37,21 +41,38 @@ type WindowFoundation struct {
 	paneCh          chan *Block
 	waitForRepaint  chan bool
 	doRepaintWindow chan bool
+
+	// Chords typed in the window run these first, and then Shortcuts,
+	// before going to the key focus.
+	Accelerators *Accelerators
 }
 
+// NewWindow creates a window whose contents are width by height units, each
+// DefaultScale physical pixels across. If WindowGenerator is set, it is used
+// to create the underlying wde.Window.
 func NewWindow(parent wde.Window, width, height int) (wf *WindowFoundation, err error) {
 	wf = new(WindowFoundation)
 
-	wf.W, err = wde.NewWindow(width, height)
+	scale := DefaultScale
+	pw, ph := int(float64(width)*scale+0.5), int(float64(height)*scale+0.5)
+	if WindowGenerator != nil {
+		wf.W, err = WindowGenerator(parent, pw, ph)
+	} else {
+		wf.W, err = wde.NewWindow(pw, ph)
+	}
 	if err != nil {
 		return
 	}
 	wf.Size = geom.Coord{float64(width), float64(height)}
 	wf.Initialize()
+	wf.Scale = scale
 
 	if ReportIDs {
 		Report(wf.ID, "window")
 	}
+	wf.SetAccessible(Accessible{Role: RoleWindow})
+	Accessibility.addRoot(&wf.Block)
+	Translations.addWindow(&wf.Block)
 	// Report(wf.ID, "is window")
 
 	go wf.handleWindowEvents()
@@ -65,24 +86,42 @@ func (wf *WindowFoundation) Initialize() {
 	wf.Foundation.Initialize()
 
 	wf.DrawOp = draw.Src
+	wf.Type = "window"
 
 	wf.waitForRepaint = make(chan bool)
 	wf.doRepaintWindow = make(chan bool)
 	wf.Invalidations = make(chan Invalidation, 1)
 	wf.paneCh = make(chan *Block, 1)
 
-	wf.Paint = LookupPaint("window", wf)
+	wf.Paint = ThemedPaint(&wf.Block, "window", wf)
 	wf.DrawOp = draw.Over
 
+	wf.Accelerators = NewAccelerators(Shortcuts)
+
 	// Report("wfound is", wf.ID)
 
 	wf.HasKeyFocus = true
+	wf.State |= StateFocused
+	wf.accessState = wf.State
 }
 
 func (wf *WindowFoundation) SetPane(b *Block) {
 	wf.paneCh <- b
 }
 
+// SetScale changes the number of physical pixels per unit. The window keeps
+// its physical size, so its contents are laid out again.
+func (wf *WindowFoundation) SetScale(scale float64) {
+	wf.UserEventsIn <- ScaleEvent{
+		Scale: scale,
+	}
+}
+
+// toLogical converts a window position, in physical pixels, to units.
+func (wf *WindowFoundation) toLogical(p image.Point) geom.Coord {
+	return geom.Coord{float64(p.X) / wf.Scale, float64(p.Y) / wf.Scale}
+}
+
 func (wf *WindowFoundation) setPane(b *Block) {
 	if wf.pane != nil {
 		wf.RemoveBlock(wf.pane)
@@ -97,7 +136,7 @@ func (wf *WindowFoundation) Show() {
 	wf.Invalidate()
 }
 
-// wraps mouse events with float64 coordinates
+// wraps mouse events with float64 coordinates, in units rather than pixels
 func (wf *WindowFoundation) handleWindowEvents() {
 	for e := range wf.W.EventChan() {
 		ev := Event{
@@ -105,83 +144,83 @@ func (wf *WindowFoundation) handleWindowEvents() {
 		}
 		switch e := e.(type) {
 		case wde.CloseEvent:
-			wf.UserEventsIn.SendOrDrop(CloseEvent{
+			wf.SendEvent(CloseEvent{
 				Event:      ev,
 				CloseEvent: e,
 			})
 		case wde.MouseMovedEvent:
-			wf.UserEventsIn.SendOrDrop(MouseMovedEvent{
+			wf.SendEvent(MouseMovedEvent{
 				Event:           ev,
 				MouseMovedEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
-				From: geom.Coord{float64(e.From.X), float64(e.From.Y)},
+				From: wf.toLogical(e.From),
 			})
 		case wde.MouseDownEvent:
-			wf.UserEventsIn.SendOrDrop(MouseDownEvent{
+			wf.SendEvent(MouseDownEvent{
 				Event:          ev,
 				MouseDownEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
 			})
 		case wde.MouseUpEvent:
 			// Report("wde.MouseUpEvent")
-			wf.UserEventsIn.SendOrDrop(MouseUpEvent{
+			wf.SendEvent(MouseUpEvent{
 				Event:        ev,
 				MouseUpEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
 			})
 		case wde.MouseDraggedEvent:
-			wf.UserEventsIn.SendOrDrop(MouseDraggedEvent{
+			wf.SendEvent(MouseDraggedEvent{
 				Event:             ev,
 				MouseDraggedEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
-				From: geom.Coord{float64(e.From.X), float64(e.From.Y)},
+				From: wf.toLogical(e.From),
 			})
 		case wde.MouseEnteredEvent:
-			wf.UserEventsIn.SendOrDrop(MouseEnteredEvent{
+			wf.SendEvent(MouseEnteredEvent{
 				Event:             ev,
 				MouseEnteredEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
-				From: geom.Coord{float64(e.From.X), float64(e.From.Y)},
+				From: wf.toLogical(e.From),
 			})
 		case wde.MouseExitedEvent:
-			wf.UserEventsIn.SendOrDrop(MouseExitedEvent{
+			wf.SendEvent(MouseExitedEvent{
 				Event:            ev,
 				MouseExitedEvent: e,
 				MouseLocator: MouseLocator{
-					Loc: geom.Coord{float64(e.Where.X), float64(e.Where.Y)},
+					Loc: wf.toLogical(e.Where),
 				},
-				From: geom.Coord{float64(e.From.X), float64(e.From.Y)},
+				From: wf.toLogical(e.From),
 			})
 		case wde.KeyDownEvent:
-			wf.UserEventsIn.SendOrDrop(KeyDownEvent{
+			wf.SendEvent(KeyDownEvent{
 				Event:        ev,
 				KeyDownEvent: e,
 			})
 		case wde.KeyUpEvent:
-			wf.UserEventsIn.SendOrDrop(KeyUpEvent{
+			wf.SendEvent(KeyUpEvent{
 				Event:      ev,
 				KeyUpEvent: e,
 			})
 		case wde.KeyTypedEvent:
-			wf.UserEventsIn.SendOrDrop(KeyTypedEvent{
+			wf.SendEvent(KeyTypedEvent{
 				Event:         ev,
 				KeyTypedEvent: e,
 			})
 		case wde.ResizeEvent:
 			wf.ResizeEvents.Stack(ResizeEvent{
 				Size: geom.Coord{
-					X: float64(e.Width),
-					Y: float64(e.Height),
+					X: float64(e.Width) / wf.Scale,
+					Y: float64(e.Height) / wf.Scale,
 				},
 			})
 		}
@@ -206,6 +245,45 @@ func (wf *WindowFoundation) HandleEvents() {
 	}
 }
 
+func (wf *WindowFoundation) HandleEvent(e interface{}) {
+	switch e := e.(type) {
+	case ScaleEvent:
+		wf.DoScaleEvent(e)
+	case CloseEvent:
+		Accessibility.removeRoot(&wf.Block)
+		forgetFocus(&wf.Block)
+		Translations.removeWindow(&wf.Block)
+		wf.Foundation.HandleEvent(e)
+	case KeyTypedEvent:
+		focus := focusIn(&wf.Block)
+		if focus == nil {
+			focus = &wf.Block
+		}
+		if !wf.Accelerators.Dispatch(ChordOf(e), focus) {
+			wf.Foundation.HandleEvent(e)
+		}
+	default:
+		wf.Foundation.HandleEvent(e)
+	}
+}
+
+// DoScaleEvent keeps the window's physical size, and resizes its contents
+// to match the new scale.
+func (wf *WindowFoundation) DoScaleEvent(e ScaleEvent) {
+	if e.Scale <= 0 || e.Scale == wf.Scale {
+		return
+	}
+	physical := wf.Size.Times(wf.Scale)
+	wf.Foundation.DoScaleEvent(e)
+	wf.DoResizeEvent(ResizeEvent{
+		Size: physical.Times(1 / wf.Scale),
+	})
+	if wf.pane != nil {
+		wf.PlaceBlock(wf.pane, geom.Rect{geom.Coord{}, wf.Size})
+	}
+	wf.Invalidate()
+}
+
 func (wf *WindowFoundation) handleWindowDrawing() {
 
 	waitingForRepaint := false
@@ -246,7 +324,7 @@ func (wf *WindowFoundation) handleWindowDrawing() {
 			// Report("window drawing done")
 			var srs []image.Rectangle
 			for _, ir := range invalidRects {
-				sr := RectangleForRect(ir)
+				sr := ScaledRectangleForRect(ir, wf.Scale).Intersect(scrBuf.Bounds())
 				si := scrBuf.SubImage(sr)
 				srs = append(srs, sr)
 				draw.Draw(scr, scr.Bounds(), si, image.Point{}, draw.Src)
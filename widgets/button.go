@@ -21,15 +21,18 @@ import (
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
 	"github.com/skelterjohn/go.wde"
+	"image"
 	"image/color"
 	"image/draw"
 	"math"
+	"sync"
 )
 
-type Clicker chan wde.Button
-
 type ButtonConfig struct {
 	Color color.Color
+	// If not nil, the caption is looked up in uik.Translations, and
+	// follows changes of locale, instead of being the button's text.
+	Message *uik.Message
 }
 
 type Button struct {
@@ -37,14 +40,26 @@ type Button struct {
 	Label   *Label
 	pressed bool
 
+	// shows the chord bound with BindShortcut, if there is one
+	shortcut                *Label
+	shortcutText            string
+	labelHint, shortcutHint uik.SizeHint
+
+	// shows the icon of the action set with SetAction, if it has one
+	icon      *Image
+	iconImage image.Image
+	iconHint  uik.SizeHint
+
+	actionMu sync.Mutex
+	action   *uik.Action
+
 	config ButtonConfig
 
 	setConfig chan ButtonConfig
 	getConfig chan ButtonConfig
 
-	Clickers      map[Clicker]bool
-	AddClicker    chan Clicker
-	RemoveClicker chan Clicker
+	// Clicked gets the mouse button that clicked it.
+	Clicked uik.Signal[wde.Button]
 }
 
 func NewButton(label string) (b *Button) {
@@ -52,16 +67,14 @@ func NewButton(label string) (b *Button) {
 
 	b.Initialize()
 
-	b.Paint = uik.LookupPaint("widgets.Button", b)
+	b.Paint = uik.ThemedPaint(&b.Block, "widgets.Button", b)
 
 	if uik.ReportIDs {
 		uik.Report(b.ID, "button")
 	}
 
 	b.Label.SetConfig(LabelConfig{
-		Text:     label,
-		FontSize: 12,
-		Color:    color.Black,
+		Text: label,
 	})
 
 	go b.handleEvents()
@@ -73,17 +86,17 @@ func (b *Button) Initialize() {
 	b.Foundation.Initialize()
 
 	b.DrawOp = draw.Over
+	b.Type = "widgets.Button"
 
 	b.Label = NewLabel(b.Size, LabelConfig{
-		Text:     "",
-		FontSize: 12,
-		Color:    color.Black,
+		Text: "",
 	})
 	b.AddBlock(&b.Label.Block)
 
-	b.Clickers = map[Clicker]bool{}
-	b.AddClicker = make(chan Clicker, 1)
-	b.RemoveClicker = make(chan Clicker, 1)
+	b.SetAccessible(uik.Accessible{
+		Role:    uik.RoleButton,
+		Actions: []string{"click"},
+	})
 
 	var cs geom.Coord
 	cs.X, cs.Y = b.Bounds().Size()
@@ -119,30 +132,202 @@ func safeRect(path draw2d.GraphicContext, min, max geom.Coord) {
 	path.MoveTo(x, y)
 }
 
+func (b *Button) click(which wde.Button) {
+	if b.State&uik.StateDisabled != 0 {
+		return
+	}
+	b.Clicked.Emit(which)
+	b.actionMu.Lock()
+	a := b.action
+	b.actionMu.Unlock()
+	if a != nil {
+		go a.Trigger()
+	}
+}
+
+// SetAction makes the button show a's label, icon and shortcut, be
+// disabled when a is, and trigger a when clicked, until unbind is called.
+func (b *Button) SetAction(a *uik.Action) (unbind func()) {
+	b.actionMu.Lock()
+	b.action = a
+	b.actionMu.Unlock()
+	stop := a.Watch(b.UserEventsIn, func() {
+		s := a.State()
+		if cfg := b.Label.GetConfig(); cfg.Text != s.Label {
+			cfg.Text = s.Label
+			b.Label.SetConfig(cfg)
+		}
+		if s.Shortcut != b.shortcutText {
+			b.setShortcut(s.Shortcut)
+		}
+		if s.Icon != b.iconImage {
+			b.setIcon(s.Icon)
+		}
+		if disabled := !s.Enabled; disabled != (b.State&uik.StateDisabled != 0) {
+			b.SetState(uik.StateDisabled, disabled)
+			b.styleShortcut()
+		}
+	})
+	unbind = b.TrackBinding(func() {
+		stop()
+		b.actionMu.Lock()
+		current := b.action == a
+		if current {
+			b.action = nil
+		}
+		b.actionMu.Unlock()
+		if !current {
+			return
+		}
+		b.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				b.SetState(uik.StateDisabled, false)
+				b.styleShortcut()
+				b.setIcon(nil)
+			},
+		}
+	})
+	return
+}
+
+// BindShortcut clicks the button when chord is typed, as bound in a with
+// name and scope, and shows the chord on the button.
+func (b *Button) BindShortcut(a *uik.Accelerators, name, chord string, scope *uik.Block) (unbind func(), err error) {
+	c, err := uik.ParseChord(chord)
+	if err != nil {
+		return
+	}
+	unbindChord, err := a.Bind(name, chord, scope, func() {
+		b.UserEventsIn <- uik.AccessActionEvent{Action: "click"}
+	})
+	if err != nil {
+		return
+	}
+	text := c.String()
+	b.UserEventsIn <- uik.BindingEvent{
+		Apply: func() {
+			b.setShortcut(text)
+		},
+	}
+	unbind = b.TrackBinding(func() {
+		unbindChord()
+		b.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				if b.shortcutText == text {
+					b.setShortcut("")
+				}
+			},
+		}
+	})
+	return
+}
+
+// setShortcut shows text at the end of the button, or nothing if it is
+// empty.
+func (b *Button) setShortcut(text string) {
+	b.shortcutText = text
+	b.SetAccessible(uik.Accessible{
+		Role:     uik.RoleButton,
+		Actions:  []string{"click"},
+		Shortcut: text,
+	})
+	if text == "" {
+		if b.shortcut != nil {
+			b.RemoveBlock(&b.shortcut.Block)
+			b.shortcut = nil
+			b.shortcutHint = uik.SizeHint{}
+			b.placeLabels()
+		}
+		return
+	}
+	if b.shortcut == nil {
+		b.shortcut = NewLabel(b.Size, LabelConfig{})
+		b.AddBlock(&b.shortcut.Block)
+	}
+	b.styleShortcut()
+}
+
+// styleShortcut shows the shortcut in the colour the theme, and its style
+// sheet, give it for the button as it is now.
+func (b *Button) styleShortcut() {
+	if b.shortcut == nil {
+		return
+	}
+	b.shortcut.SetConfig(LabelConfig{
+		Text:  b.shortcutText,
+		Color: b.StyleColor("shortcut"),
+	})
+}
+
+// setIcon shows img at the start of the button, or nothing if it is nil.
+func (b *Button) setIcon(img image.Image) {
+	b.iconImage = img
+	if img == nil {
+		if b.icon != nil {
+			b.RemoveBlock(&b.icon.Block)
+			b.icon = nil
+			b.iconHint = uik.SizeHint{}
+			b.placeLabels()
+		}
+		return
+	}
+	if b.icon == nil {
+		b.icon = NewImage(ImageConfig{Image: img})
+		b.AddBlock(&b.icon.Block)
+		return
+	}
+	b.icon.SetConfig(ImageConfig{Image: img})
+}
+
+// placeLabels puts the icon, if there is one, at the start of the button,
+// the shortcut, if there is one, at the end, and the label in the rest.
+func (b *Button) placeLabels() {
+	lbounds := b.Bounds()
+	if b.icon != nil {
+		padding := b.StyleMetric("padding") / 2
+		isize := b.iconHint.PreferredSize
+		isize.Y = math.Min(isize.Y, lbounds.Max.Y-lbounds.Min.Y-2*padding)
+		ibounds := lbounds
+		ibounds.Min.X += padding
+		ibounds.Max.X = ibounds.Min.X + isize.X
+		ibounds.Min.Y = (lbounds.Min.Y + lbounds.Max.Y - isize.Y) / 2
+		ibounds.Max.Y = ibounds.Min.Y + isize.Y
+		lbounds.Min.X = ibounds.Max.X
+		b.PlaceBlock(&b.icon.Block, ibounds)
+	}
+	if b.shortcut != nil {
+		sbounds := lbounds
+		sbounds.Min.X = sbounds.Max.X - b.shortcutHint.PreferredSize.X - b.StyleMetric("padding")/2
+		lbounds.Max.X = sbounds.Min.X
+		b.PlaceBlock(&b.shortcut.Block, sbounds)
+	}
+	b.PlaceBlock(&b.Label.Block, lbounds)
+}
+
 func (b *Button) handleEvents() {
 
 	for {
 		select {
 		case e := <-b.UserEvents:
 			switch e := e.(type) {
-			case uik.MouseEnteredEvent:
-
-			case uik.MouseExitedEvent:
-
 			case uik.MouseDownEvent:
 				b.pressed = true
+				b.SetState(uik.StatePressed, true)
 				b.Invalidate()
 			case uik.MouseUpEvent:
 				b.pressed = false
+				b.SetState(uik.StatePressed, false)
 				// uik.Report(b.ID, "was clicked")
-				for c := range b.Clickers {
-					select {
-					case c <- e.Which:
-					default:
-					}
-				}
+				b.click(e.Which)
 				b.Invalidate()
 				// go uik.ShowBuffer("button buffer", b.Buffer)
+			case uik.AccessActionEvent:
+				if e.Action == "click" {
+					b.click(wde.LeftButton)
+				}
+			case uik.ThemeEvent:
+				b.Foundation.HandleEvent(e)
+				b.styleShortcut()
 			default:
 				b.Foundation.HandleEvent(e)
 			}
@@ -152,24 +337,41 @@ func (b *Button) handleEvents() {
 			if b.Size != e.Size {
 				b.Foundation.DoResizeEvent(e)
 			}
-			lbounds := b.Bounds()
-			b.PlaceBlock(&b.Label.Block, lbounds)
-		case c := <-b.AddClicker:
-			b.Clickers[c] = true
-		case c := <-b.RemoveClicker:
-			if b.Clickers[c] {
-				delete(b.Clickers, c)
-			}
+			b.placeLabels()
 		case bsh := <-b.BlockSizeHints:
-			sh := bsh.SizeHint
-			sh.PreferredSize.X += 10
-			sh.PreferredSize.Y += 10
+			if b.shortcut != nil && bsh.Block == &b.shortcut.Block {
+				b.shortcutHint = bsh.SizeHint
+				b.placeLabels()
+			} else if b.icon != nil && bsh.Block == &b.icon.Block {
+				b.iconHint = bsh.SizeHint
+				b.placeLabels()
+			} else {
+				b.labelHint = bsh.SizeHint
+			}
+			sh := b.labelHint
+			if b.shortcut != nil {
+				sh.PreferredSize.X += b.StyleMetric("spacing") + b.shortcutHint.PreferredSize.X
+				sh.PreferredSize.Y = math.Max(sh.PreferredSize.Y, b.shortcutHint.PreferredSize.Y)
+			}
+			if b.icon != nil {
+				sh.PreferredSize.X += b.StyleMetric("spacing") + b.iconHint.PreferredSize.X
+				sh.PreferredSize.Y = math.Max(sh.PreferredSize.Y, b.iconHint.PreferredSize.Y)
+			}
+			padding := b.StyleMetric("padding")
+			sh.PreferredSize.X += padding
+			sh.PreferredSize.Y += padding
 			sh.PreferredSize.X = math.Max(sh.PreferredSize.X, b.Size.X)
 			sh.PreferredSize.Y = math.Max(sh.PreferredSize.Y, b.Size.Y)
 			sh.MaxSize.X = math.Inf(1)
 			sh.MaxSize.Y = math.Inf(1)
 			b.SetSizeHint(sh)
-		case b.config = <-b.setConfig:
+		case cfg := <-b.setConfig:
+			if cfg.Message != b.config.Message {
+				lcfg := b.Label.GetConfig()
+				lcfg.Message = cfg.Message
+				b.Label.SetConfig(lcfg)
+			}
+			b.config = cfg
 			b.Invalidate()
 		case b.getConfig <- b.config:
 		}
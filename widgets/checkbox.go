@@ -19,26 +19,36 @@ package widgets
 import (
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
+	"sync"
 )
 
-type Checker chan bool
-
 type Checkbox struct {
 	uik.Block
 
 	state, pressed, pressHover bool
+
+	// Toggled gets whether the box is now checked.
+	Toggled uik.Signal[bool]
+
+	checked *uik.BoolValue
+
+	actionMu sync.Mutex
+	action   *uik.Action
 }
 
 func NewCheckbox(size geom.Coord) (c *Checkbox) {
 	c = new(Checkbox)
 	c.Initialize()
-	c.Paint = uik.LookupPaint("widgets.Checkbox", c)
+	c.Type = "widgets.Checkbox"
+	c.Paint = uik.ThemedPaint(&c.Block, "widgets.Checkbox", c)
 
 	if uik.ReportIDs {
 		uik.Report(c.ID, "checkbox")
 	}
 	c.Size = size
 
+	c.publish()
+
 	go c.handleEvents()
 
 	c.SetSizeHint(uik.SizeHint{
@@ -50,32 +60,137 @@ func NewCheckbox(size geom.Coord) (c *Checkbox) {
 	return
 }
 
+// publish tells the accessibility tree whether the box is checked.
+func (c *Checkbox) publish() {
+	a := uik.Accessible{
+		Role:    uik.RoleCheckbox,
+		Name:    c.Name,
+		Actions: []string{"toggle"},
+	}
+	if c.state {
+		a.States = []string{"checked"}
+	}
+	c.SetAccessible(a)
+}
+
+func (c *Checkbox) setChecked(state bool) {
+	if state == c.state {
+		return
+	}
+	c.state = state
+	c.publish()
+	c.Invalidate()
+	if c.checked != nil {
+		c.checked.Set(state)
+	}
+	c.Toggled.Emit(state)
+}
+
+// BindChecked checks the box when v is true, and sets v when the box is
+// toggled, until unbind is called.
+func (c *Checkbox) BindChecked(v *uik.BoolValue) (unbind func()) {
+	stop := v.Watch(c.UserEventsIn, func() {
+		c.checked = v
+		c.setChecked(v.Get())
+	})
+	unbind = c.TrackBinding(func() {
+		stop()
+		c.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				if c.checked == v {
+					c.checked = nil
+				}
+			},
+		}
+	})
+	return
+}
+
+// toggle flips the box for the user, unless it is disabled, and tells the
+// action set with SetAction, if there is one.
+func (c *Checkbox) toggle() {
+	if c.State&uik.StateDisabled != 0 {
+		return
+	}
+	c.setChecked(!c.state)
+	c.actionMu.Lock()
+	a := c.action
+	c.actionMu.Unlock()
+	if a != nil {
+		checked := c.state
+		go func() {
+			a.SetChecked(checked)
+			a.Trigger()
+		}()
+	}
+}
+
+// SetAction checks the box when a is checked, disables it when a is, and,
+// when the box is toggled, checks or unchecks a and triggers it, until
+// unbind is called.
+func (c *Checkbox) SetAction(a *uik.Action) (unbind func()) {
+	c.actionMu.Lock()
+	c.action = a
+	c.actionMu.Unlock()
+	stop := a.Watch(c.UserEventsIn, func() {
+		s := a.State()
+		c.setChecked(s.Checked)
+		c.SetState(uik.StateDisabled, !s.Enabled)
+	})
+	unbind = c.TrackBinding(func() {
+		stop()
+		c.actionMu.Lock()
+		current := c.action == a
+		if current {
+			c.action = nil
+		}
+		c.actionMu.Unlock()
+		if !current {
+			return
+		}
+		c.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				c.SetState(uik.StateDisabled, false)
+			},
+		}
+	})
+	return
+}
+
 func (c *Checkbox) handleEvents() {
 	for {
 		select {
 		case e := <-c.UserEvents:
 			switch e := e.(type) {
 			case uik.MouseEnteredEvent:
+				c.Block.HandleEvent(e)
 				if c.pressed {
 					c.pressHover = true
-					c.Invalidate()
+					c.SetState(uik.StatePressedOutside, false)
+					c.SetState(uik.StatePressed, true)
 				}
 			case uik.MouseExitedEvent:
+				c.Block.HandleEvent(e)
 				if c.pressed {
 					c.pressHover = false
-					c.Invalidate()
+					c.SetState(uik.StatePressed, false)
+					c.SetState(uik.StatePressedOutside, true)
 				}
 			case uik.MouseDownEvent:
 				c.pressed = true
 				c.pressHover = true
-				c.Invalidate()
+				c.SetState(uik.StatePressed, true)
 			case uik.MouseUpEvent:
 				if c.pressHover {
-					c.state = !c.state
-					c.Invalidate()
+					c.toggle()
 				}
 				c.pressHover = false
 				c.pressed = false
+				c.SetState(uik.StatePressed|uik.StatePressedOutside, false)
+			case uik.AccessActionEvent:
+				if e.Action == "toggle" {
+					c.toggle()
+				}
 			default:
 				c.Block.HandleEvent(e)
 			}
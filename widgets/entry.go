@@ -23,21 +23,56 @@ import (
 	"github.com/skelterjohn/go.wde"
 	"image"
 	"image/color"
+	"sync"
 	"time"
 )
 
+// how soon a click must follow the one before to count with it, for
+// selecting words with double clicks and everything with triple clicks
+const multiClickTime = 400 * time.Millisecond
+
 type Entry struct {
 	uik.Block
-	textBuffer   *image.RGBA
+	textBuffer   image.Image
 	text         []rune
+	line         uik.TextLine
 	runeOffsets  []float64
+	runeExtents  [][2]float64
 	cursor       int
 	selectCursor int
 	selecting    bool
 	selected     bool
 	textOffset   float64
-	fd           draw2d.FontData
-	fontSize     float64
+
+	lastClick       time.Time
+	lastClickCursor int
+	clicks          int
+
+	undo *uik.UndoStack
+	// the edit that typing adds to, until something else happens
+	typing *entryEdit
+	// what undoing and redoing, which may happen on other goroutines, have
+	// left to restore, and whether undoNow will restore it itself
+	restoreGuard sync.Mutex
+	restores     []entryState
+	restoreNow   bool
+
+	// Changed gets the text whenever it changes.
+	Changed uik.Signal[string]
+	// Submitted gets the text when return or enter is typed.
+	Submitted uik.Signal[string]
+
+	boundText *uik.StringValue
+}
+
+// entryState is what an edit changes, and undoing puts back.
+type entryState struct {
+	text   string
+	cursor int
+}
+
+type entryEdit struct {
+	before, after entryState
 }
 
 func NewEntry(size geom.Coord) (e *Entry) {
@@ -48,9 +83,6 @@ func NewEntry(size geom.Coord) (e *Entry) {
 		uik.Report(e.ID, "entry")
 	}
 
-	e.text = []rune("hello world")
-	e.cursor = len(e.text)
-
 	e.render()
 
 	go e.handleEvents()
@@ -70,44 +102,68 @@ func NewEntry(size geom.Coord) (e *Entry) {
 
 func (e *Entry) Initialize() {
 	e.Block.Initialize()
+	e.Type = "widgets.Entry"
+	e.undo = uik.NewUndoStack(100)
+}
 
-	e.fd = uik.DefaultFontData
-	e.fontSize = 12
+// SetText replaces the text, with the cursor at the end. It can't be
+// undone.
+func (e *Entry) SetText(text string) {
+	e.UserEventsIn <- uik.BindingEvent{
+		Apply: func() {
+			if text == string(e.text) {
+				return
+			}
+			e.setText(text)
+			e.textChanged()
+		},
+	}
 }
 
+func (e *Entry) GetText() (text string) {
+	reply := make(chan string, 1)
+	e.UserEventsIn <- uik.BindingEvent{
+		Apply: func() {
+			reply <- string(e.text)
+		},
+	}
+	text = <-reply
+	return
+}
+
+// UndoStack holds the entry's edits. Its UndoAction and RedoAction can be
+// given to buttons or shortcuts.
+func (e *Entry) UndoStack() *uik.UndoStack {
+	return e.undo
+}
+
+// render the text at the physical resolution of the entry, keeping
+// runeOffsets and runeExtents in units
 func (e *Entry) render() {
-	const stretchFactor = 1.2
+	layout := uik.LayoutText(uik.Paragraph{
+		Text: string(e.text),
+		Font: e.StyleFont("text"),
+		Size: e.StyleMetric("text.size") * e.Scale,
+	})
+	e.line = layout.Lines[0]
 
-	text := string(e.text)
+	e.runeOffsets = make([]float64, len(e.line.Carets))
+	for i, c := range e.line.Carets {
+		e.runeOffsets[i] = c / e.Scale
+	}
+	e.runeExtents = make([][2]float64, len(e.line.Extents))
+	for i, ext := range e.line.Extents {
+		e.runeExtents[i] = [2]float64{ext[0] / e.Scale, ext[1] / e.Scale}
+	}
 
-	height := uik.GetFontHeight(e.fd, e.fontSize) * stretchFactor
-	widthMax := float64(len(text)) * e.fontSize
+	e.textBuffer = layout.Render(e.StyleColor("text"))
 
-	buf := image.NewRGBA(image.Rectangle{
-		Min: image.Point{0, 0},
-		Max: image.Point{int(widthMax + 1), int(height + 1)},
+	e.SetAccessible(uik.Accessible{
+		Role:    uik.RoleEntry,
+		Name:    e.Name,
+		Value:   string(e.text),
+		Actions: []string{"focus", "set-value"},
 	})
-
-	gc := draw2d.NewGraphicContext(buf)
-	gc.Translate(0, height/stretchFactor)
-	gc.SetFontData(e.fd)
-	gc.SetFontSize(e.fontSize)
-	gc.SetStrokeColor(color.Black)
-
-	var left float64
-	e.runeOffsets = []float64{0}
-	for _, r := range e.text {
-		rt := string(r)
-		width := gc.FillString(rt)
-		gc.Translate(width, 0)
-		left += width
-		e.runeOffsets = append(e.runeOffsets, left)
-	}
-
-	e.textBuffer = buf.SubImage(image.Rectangle{
-		Min: image.Point{0, 0},
-		Max: image.Point{int(left + 1), int(height + 1)},
-	}).(*image.RGBA)
 }
 
 func (e *Entry) GrabFocus() {
@@ -120,35 +176,38 @@ func (e *Entry) GrabFocus() {
 }
 
 func (e *Entry) draw(gc draw2d.GraphicContext) {
-	if e.textOffset+e.runeOffsets[e.cursor] < 5 {
-		e.textOffset = 5 - e.runeOffsets[e.cursor]
+	margin := e.StyleMetric("margin")
+	if e.textOffset+e.runeOffsets[e.cursor] < margin {
+		e.textOffset = margin - e.runeOffsets[e.cursor]
 	}
-	if e.textOffset+e.runeOffsets[e.cursor] > e.Size.X-5 {
-		e.textOffset = e.Size.X - 5 - e.runeOffsets[e.cursor]
+	if e.textOffset+e.runeOffsets[e.cursor] > e.Size.X-margin {
+		e.textOffset = e.Size.X - margin - e.runeOffsets[e.cursor]
 	}
 
 	gc.Clear()
-	if e.HasKeyFocus {
-		gc.SetFillColor(color.RGBA{150, 150, 150, 255})
-		safeRect(gc, geom.Coord{0, 0}, e.Size)
-		gc.Fill()
-	}
-	th := float64(e.textBuffer.Bounds().Max.Y - e.textBuffer.Bounds().Min.Y)
+	gc.SetFillColor(e.StyleColor("background"))
+	safeRect(gc, geom.Coord{0, 0}, e.Size)
+	gc.Fill()
+	th := float64(e.textBuffer.Bounds().Max.Y-e.textBuffer.Bounds().Min.Y) / e.Scale
 	gc.Save()
 	gc.Translate(e.textOffset, 0)
 
 	if e.selecting {
-		start := e.runeOffsets[e.cursor]
-		end := e.runeOffsets[e.selectCursor]
+		start, end := e.cursor, e.selectCursor
 		if start > end {
 			start, end = end, start
 		}
-		gc.SetFillColor(color.RGBA{200, 200, 200, 255})
-		safeRect(gc, geom.Coord{start, 0}, geom.Coord{end, e.Size.Y})
+		// with mixed directions, the selected runes need not be next to
+		// each other
+		gc.SetFillColor(e.StyleColor("selection"))
+		for _, ext := range e.runeExtents[start:end] {
+			safeRect(gc, geom.Coord{ext[0], 0}, geom.Coord{ext[1], e.Size.Y})
+		}
 		gc.Fill()
 	}
 
 	gc.Translate(0, (e.Size.Y-th)/2)
+	gc.Scale(1/e.Scale, 1/e.Scale)
 
 	gc.DrawImage(e.textBuffer)
 	gc.Restore()
@@ -165,7 +224,8 @@ func (e *Entry) draw(gc draw2d.GraphicContext) {
 			intensity = uint8((diff * 255) / 200)
 		}
 		offset := float64(int(e.runeOffsets[e.cursor] + e.textOffset))
-		gc.SetStrokeColor(color.RGBA{A: intensity})
+		cr, cg, cb, _ := e.StyleColor("caret").RGBA()
+		gc.SetStrokeColor(color.RGBA{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), intensity})
 		gc.MoveTo(offset, 0)
 		gc.LineTo(offset, e.Size.Y)
 		gc.Stroke()
@@ -177,15 +237,325 @@ func (e *Entry) draw(gc draw2d.GraphicContext) {
 }
 
 func (e *Entry) cursorForCoord(p geom.Coord) (cursor int) {
-	textX := p.X - e.textOffset
-	for i, co := range e.runeOffsets {
-		// uik.Report(i, co)
-		if textX > co {
-			cursor = i
-		} else {
+	cursor = e.line.CaretIndex((p.X - e.textOffset) * e.Scale)
+	cursor = uik.SnapToGrapheme(e.text, cursor)
+	return
+}
+
+// setText replaces all of the text, leaving the cursor at the end.
+func (e *Entry) setText(text string) {
+	e.restore(entryState{
+		text:   text,
+		cursor: len([]rune(text)),
+	})
+}
+
+// restore puts the text and cursor back as they were.
+func (e *Entry) restore(s entryState) {
+	e.text = []rune(s.text)
+	e.cursor = s.cursor
+	if e.cursor > len(e.text) {
+		e.cursor = len(e.text)
+	}
+	e.selecting = false
+	e.typing = nil
+	e.render()
+	e.Invalidate()
+}
+
+// textChanged tells whatever is bound to or listening to the text about an
+// edit.
+func (e *Entry) textChanged() {
+	text := string(e.text)
+	if e.boundText != nil {
+		e.boundText.Set(text)
+	}
+	e.Changed.Emit(text)
+}
+
+// selection returns the selected runes' bounds, which are both the cursor
+// if nothing is selected.
+func (e *Entry) selection() (start, end int) {
+	start, end = e.cursor, e.cursor
+	if e.selecting {
+		end = e.selectCursor
+		if end < start {
+			start, end = end, start
+		}
+	}
+	return
+}
+
+func (e *Entry) selectRange(start, end int) {
+	e.selectCursor, e.cursor = start, end
+	e.selecting = start != end
+	e.typing = nil
+	e.Invalidate()
+}
+
+// moveTo puts the cursor at i, moving the end of the selection if extend
+// is true, and otherwise ending it.
+func (e *Entry) moveTo(i int, extend bool) {
+	if extend && !e.selecting {
+		e.selecting = true
+		e.selectCursor = e.cursor
+	}
+	if !extend {
+		e.selecting = false
+	}
+	e.cursor = i
+	if e.selecting && e.cursor == e.selectCursor {
+		e.selecting = false
+	}
+	e.typing = nil
+	e.Invalidate()
+}
+
+// replace puts text in place of the runes from start to end, as an edit
+// that can be undone. Typing goes into the same edit as the typing just
+// before it.
+func (e *Entry) replace(start, end int, text []rune, name string, typing bool) {
+	before := entryState{string(e.text), e.cursor}
+	runes := make([]rune, 0, len(e.text)-(end-start)+len(text))
+	runes = append(runes, e.text[:start]...)
+	runes = append(runes, text...)
+	runes = append(runes, e.text[end:]...)
+	e.text = runes
+	e.cursor = start + len(text)
+	e.selecting = false
+	after := entryState{string(e.text), e.cursor}
+
+	if typing && e.typing != nil && e.typing.after == before {
+		e.typing.after = after
+	} else {
+		edit := &entryEdit{before, after}
+		e.undo.Push(uik.Command{
+			Name: name,
+			Do: func() {
+				e.restoreLater(edit.after)
+			},
+			Undo: func() {
+				e.restoreLater(edit.before)
+			},
+		})
+		e.typing = nil
+		if typing {
+			e.typing = edit
+		}
+	}
+	e.render()
+	e.Invalidate()
+	e.textChanged()
+}
+
+// restoreLater restores s on the entry's goroutine, since the undo stack
+// may be used from anywhere, such as by its UndoAction. From undoNow,
+// which is on that goroutine already, it leaves s for undoNow.
+func (e *Entry) restoreLater(s entryState) {
+	e.restoreGuard.Lock()
+	e.restores = append(e.restores, s)
+	now := e.restoreNow
+	e.restoreGuard.Unlock()
+	if now {
+		return
+	}
+	e.UserEventsIn <- uik.BindingEvent{
+		Apply: e.restorePending,
+	}
+}
+
+// restorePending restores what undoing and redoing have left, if it
+// hasn't been already.
+func (e *Entry) restorePending() {
+	e.restoreGuard.Lock()
+	restores := e.restores
+	e.restores = nil
+	e.restoreGuard.Unlock()
+	for _, s := range restores {
+		e.restore(s)
+	}
+	if len(restores) != 0 {
+		e.textChanged()
+	}
+}
+
+// undoNow undoes, or redoes, from the keyboard. The text changes straight
+// away, so that the keys typed after apply to it, and don't add to the
+// edit undone.
+func (e *Entry) undoNow(redo bool) {
+	e.typing = nil
+	e.restoreGuard.Lock()
+	e.restoreNow = true
+	e.restoreGuard.Unlock()
+	if redo {
+		e.undo.Redo()
+	} else {
+		e.undo.Undo()
+	}
+	e.restoreGuard.Lock()
+	e.restoreNow = false
+	e.restoreGuard.Unlock()
+	e.restorePending()
+}
+
+// deleteTo deletes the selection, or what is between the cursor and i.
+func (e *Entry) deleteTo(i int) {
+	start, end := e.selection()
+	if start == end {
+		start, end = i, e.cursor
+		if start > end {
+			start, end = end, start
+		}
+	}
+	if start == end {
+		return
+	}
+	e.replace(start, end, nil, "Delete", false)
+}
+
+// wordStep moves a word from i, the way that the arrow pointing in
+// direction delta points.
+func (e *Entry) wordStep(i, delta int) int {
+	if e.line.Level%2 == 1 {
+		delta = -delta
+	}
+	if delta < 0 {
+		return uik.PrevWord(e.text, i)
+	}
+	return uik.NextWord(e.text, i)
+}
+
+func (e *Entry) mouseDown(ev uik.MouseDownEvent) {
+	e.GrabFocus()
+	cursor := e.cursorForCoord(ev.Loc)
+	now := time.Now()
+	if now.Sub(e.lastClick) < multiClickTime && cursor == e.lastClickCursor {
+		e.clicks++
+	} else {
+		e.clicks = 1
+	}
+	e.lastClick, e.lastClickCursor = now, cursor
+	switch e.clicks {
+	case 1:
+		e.moveTo(cursor, false)
+		e.selecting = true
+		e.selectCursor = e.cursor
+	case 2:
+		start, end := uik.WordAt(e.text, cursor)
+		e.selectRange(start, end)
+	default:
+		e.selectRange(0, len(e.text))
+	}
+}
+
+func (e *Entry) keyTyped(ev uik.KeyTypedEvent) {
+	chord := uik.ChordOf(ev)
+	shift := chord.Mods&uik.ModShift != 0
+	command := chord.Mods&(uik.ModCtrl|uik.ModSuper) != 0
+	// word moves are ctrl on most systems, and alt on some
+	word := chord.Mods&(uik.ModCtrl|uik.ModAlt) != 0
+
+	switch ev.Key {
+	case wde.KeyBackspace:
+		i := uik.PrevGrapheme(e.text, e.cursor)
+		if word {
+			i = uik.PrevWord(e.text, e.cursor)
+		}
+		e.deleteTo(i)
+	case wde.KeyDelete:
+		i := uik.NextGrapheme(e.text, e.cursor)
+		if word {
+			i = uik.NextWord(e.text, e.cursor)
+		}
+		e.deleteTo(i)
+	case wde.KeyLeftArrow, wde.KeyRightArrow:
+		delta := 1
+		if ev.Key == wde.KeyLeftArrow {
+			delta = -1
+		}
+		switch {
+		case word:
+			e.moveTo(e.wordStep(e.cursor, delta), shift)
+		case e.selecting && !shift:
+			// an arrow goes to that end of the selection
+			start, end := e.selection()
+			if (delta < 0) != (e.line.Level%2 == 1) {
+				e.moveTo(start, false)
+			} else {
+				e.moveTo(end, false)
+			}
+		default:
+			// arrows move the way they point, whichever way the text
+			// under the caret runs
+			e.moveTo(e.line.MoveVisually(e.cursor, delta, uik.GraphemeBoundaries(e.text)), shift)
+		}
+	case wde.KeyHome, wde.KeyUpArrow:
+		e.moveTo(0, shift)
+	case wde.KeyEnd, wde.KeyDownArrow:
+		e.moveTo(len(e.text), shift)
+	case wde.KeyReturn, wde.KeyEnter:
+		e.typing = nil
+		e.Submitted.Emit(string(e.text))
+	case wde.KeyA:
+		if command {
+			e.selectRange(0, len(e.text))
+			break
+		}
+		e.typeGlyph(ev.Glyph)
+	case wde.KeyZ:
+		if command {
+			e.undoNow(shift)
 			break
 		}
+		e.typeGlyph(ev.Glyph)
+	case wde.KeyY:
+		if command {
+			e.undoNow(true)
+			break
+		}
+		e.typeGlyph(ev.Glyph)
+	default:
+		if command {
+			break
+		}
+		e.typeGlyph(ev.Glyph)
+	}
+}
+
+// typeGlyph puts what was typed in place of the selection.
+func (e *Entry) typeGlyph(glyph string) {
+	if glyph == "" {
+		return
 	}
+	start, end := e.selection()
+	e.replace(start, end, []rune(glyph), "Typing", true)
+	// a combining mark joins the cluster before it, and the caret goes
+	// after the whole cluster
+	e.cursor = uik.NextGrapheme(e.text, uik.SnapToGrapheme(e.text, e.cursor-1))
+	if e.typing != nil {
+		e.typing.after.cursor = e.cursor
+	}
+}
+
+// BindText shows v as the entry's text, and sets v as the text is edited,
+// until unbind is called.
+func (e *Entry) BindText(v *uik.StringValue) (unbind func()) {
+	stop := v.Watch(e.UserEventsIn, func() {
+		e.boundText = v
+		if text := v.Get(); text != string(e.text) {
+			e.setText(text)
+		}
+	})
+	unbind = e.TrackBinding(func() {
+		stop()
+		e.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				if e.boundText == v {
+					e.boundText = nil
+				}
+			},
+		}
+	})
 	return
 }
 
@@ -197,14 +567,7 @@ func (e *Entry) handleEvents() {
 		case ev := <-e.UserEvents:
 			switch ev := ev.(type) {
 			case uik.MouseDownEvent:
-				e.GrabFocus()
-				newcursor := e.cursorForCoord(ev.Loc)
-				if e.cursor != newcursor {
-					e.cursor = newcursor
-					e.Invalidate()
-				}
-				e.selecting = true
-				e.selectCursor = e.cursor
+				e.mouseDown(ev)
 			case uik.MouseUpEvent:
 				if e.selecting {
 					e.Invalidate()
@@ -222,76 +585,21 @@ func (e *Entry) handleEvents() {
 					e.Invalidate()
 				}
 			case uik.KeyTypedEvent:
-				// uik.Report("key", ev.Code, ev.Letter)
-				switch ev.Key {
-				case wde.KeyBackspace:
-					if len(e.text) == 0 {
-						break
-					}
-					if !e.selecting && e.cursor == 0 {
-						break
-					}
-					start, end := e.cursor-1, e.cursor
-					if e.selecting {
-						start = e.cursor
-						end = e.selectCursor
-						if end < start {
-							start, end = end, start
-						}
-					}
-					copy(e.text[start:], e.text[end:])
-					e.text = e.text[:len(e.text)-(end-start)]
-					e.cursor = start
-				case wde.KeyDelete:
-					if len(e.text) == 0 {
-						break
-					}
-					if !e.selecting && e.cursor == len(e.text) {
-						break
-					}
-					start, end := e.cursor, e.cursor+1
-					if e.selecting {
-						start = e.cursor
-						end = e.selectCursor
-						if end < start {
-							start, end = end, start
-						}
-					}
-					copy(e.text[start:], e.text[end:])
-					e.text = e.text[:len(e.text)-(end-start)]
-					e.cursor = start
-				case wde.KeyLeftArrow:
-					if e.cursor > 0 {
-						e.cursor--
-					}
-				case wde.KeyRightArrow:
-					if e.cursor < len(e.text) {
-						e.cursor++
-					}
-				default:
-					if ev.Glyph != "" {
-						start, end := e.cursor, e.cursor
-						if e.selecting {
-							end = e.selectCursor
-							if end < start {
-								start, end = end, start
-							}
-						}
-						head := e.text[:start]
-						tail := e.text[end:]
-						e.text = make([]rune, len(head)+len(tail)+1)[:0]
-						e.text = append(e.text, head...)
-						e.text = append(e.text, []rune(ev.Glyph)[0])
-						e.text = append(e.text, tail...)
-						e.cursor = start + 1
-					}
+				e.keyTyped(ev)
+			case uik.AccessActionEvent:
+				switch ev.Action {
+				case "focus":
+					e.GrabFocus()
+				case "set-value":
+					e.replace(0, len(e.text), []rune(ev.Value), "Set Text", false)
 				}
-				e.selecting = false
-				e.render()
-				e.Invalidate()
 			case uik.KeyFocusEvent:
 				e.HandleEvent(ev)
+				e.render()
 				e.Invalidate()
+			case uik.ScaleEvent, uik.ThemeEvent:
+				e.HandleEvent(ev)
+				e.render()
 			default:
 				e.HandleEvent(ev)
 			}
@@ -26,6 +26,8 @@ import (
 
 type ImageConfig struct {
 	Image image.Image
+	// What the image shows, for assistive tools.
+	Description string
 }
 
 func (ic ImageConfig) ImageSize() (s geom.Coord) {
@@ -57,6 +59,7 @@ func NewImage(cfg ImageConfig) (i *Image) {
 
 func (i *Image) Initialize() {
 	i.Block.Initialize()
+	i.Type = "widgets.Image"
 
 	i.setConfig = make(chan ImageConfig, 1)
 	i.getConfig = make(chan ImageConfig, 1)
@@ -89,6 +92,10 @@ func (i *Image) draw(gc draw2d.GraphicContext) {
 
 func (i *Image) updateConfig(config ImageConfig) {
 	i.config = config
+	i.SetAccessible(uik.Accessible{
+		Role: uik.RoleImage,
+		Name: config.Description,
+	})
 	i.SetSizeHint(uik.SizeHint{
 		MinSize:       geom.Coord{},
 		PreferredSize: i.config.ImageSize(),
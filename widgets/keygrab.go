@@ -21,7 +21,6 @@ import (
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
 	"image"
-	"image/color"
 )
 
 type KeyGrab struct {
@@ -33,6 +32,7 @@ type KeyGrab struct {
 func NewKeyGrab(size geom.Coord) (l *KeyGrab) {
 	l = new(KeyGrab)
 	l.Initialize()
+	l.Type = "widgets.KeyGrab"
 	if uik.ReportIDs {
 		uik.Report(l.ID, "keygrab")
 	}
@@ -57,19 +57,20 @@ func NewKeyGrab(size geom.Coord) (l *KeyGrab) {
 }
 
 func (l *KeyGrab) render() {
-	l.kbuf = uik.RenderString(l.key, uik.DefaultFontData, 12, color.Black)
+	fd := l.StyleFont("text")
+	size := l.StyleMetric("text.size")
+	l.kbuf = uik.RenderString(l.key, fd, size*l.Scale, l.StyleColor("text"))
 }
 
 func (l *KeyGrab) draw(gc draw2d.GraphicContext) {
 	gc.Clear()
-	if l.HasKeyFocus {
-		gc.SetFillColor(color.RGBA{150, 150, 150, 255})
-		safeRect(gc, geom.Coord{0, 0}, l.Size)
-		gc.FillStroke()
-	}
-	tw := float64(l.kbuf.Bounds().Max.X - l.kbuf.Bounds().Min.X)
-	th := float64(l.kbuf.Bounds().Max.Y - l.kbuf.Bounds().Min.Y)
+	gc.SetFillColor(l.StyleColor("background"))
+	safeRect(gc, geom.Coord{0, 0}, l.Size)
+	gc.FillStroke()
+	tw := float64(l.kbuf.Bounds().Max.X-l.kbuf.Bounds().Min.X) / l.Scale
+	th := float64(l.kbuf.Bounds().Max.Y-l.kbuf.Bounds().Min.Y) / l.Scale
 	gc.Translate((l.Size.X-tw)/2, (l.Size.Y-th)/2)
+	gc.Scale(1/l.Scale, 1/l.Scale)
 	gc.DrawImage(l.kbuf)
 }
 
@@ -95,7 +96,11 @@ func (l *KeyGrab) handleEvents() {
 				l.Invalidate()
 			case uik.KeyFocusEvent:
 				l.HandleEvent(e)
+				l.render()
 				l.Invalidate()
+			case uik.ScaleEvent, uik.ThemeEvent:
+				l.HandleEvent(e)
+				l.render()
 			default:
 				l.HandleEvent(e)
 			}
@@ -18,25 +18,57 @@ package widgets
 
 import (
 	"code.google.com/p/draw2d/draw2d"
+	"fmt"
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
 	"image"
 	"image/color"
+	"log"
+	"math"
+	"strings"
 )
 
+// The zero values of FontSize and Color mean the label's theme decides.
+//
+// Without Wrap or Ellipsis, the label is exactly as large as its text. With
+// them, lines are broken or cut short at the label's width, and the label
+// asks for the height its text needs at that width.
 type LabelConfig struct {
 	Text     string
 	FontSize float64
 	Color    color.Color
+
+	Wrap        uik.WrapMode
+	Align       uik.Alignment
+	Direction   uik.Direction
+	LineSpacing float64
+	MaxLines    int
+	Ellipsis    bool
+
+	// If true, Text is parsed with uik.ParseMarkup, for bold, italic,
+	// underlined or colored pieces. Bold and italic are synthesised for
+	// fonts, like the default, without bold or italic faces. Text that
+	// fails to parse is shown as it is.
+	Markup bool
+
+	// If not nil, the label shows this message in the current locale
+	// instead of Text, and changes with the locale. Text is shown if no
+	// catalog has the message.
+	Message *uik.Message
 }
 
 type Label struct {
 	uik.Block
 
-	data      LabelConfig
+	data LabelConfig
+	// parsed from data.Text, if it is markup
+	runs      []uik.TextRun
 	setConfig chan LabelConfig
 	getConfig chan LabelConfig
 
+	// the text shown, after translation
+	text string
+
 	tbuf image.Image
 }
 
@@ -50,7 +82,7 @@ func NewLabel(size geom.Coord, data LabelConfig) (l *Label) {
 	// uik.Report(l.ID, "label")
 
 	l.Size = size
-	l.data = data
+	l.setData(data)
 
 	l.render()
 
@@ -61,6 +93,7 @@ func NewLabel(size geom.Coord, data LabelConfig) (l *Label) {
 
 func (l *Label) Initialize() {
 	l.Block.Initialize()
+	l.Type = "widgets.Label"
 
 	l.setConfig = make(chan LabelConfig, 1)
 	l.getConfig = make(chan LabelConfig, 1)
@@ -80,17 +113,117 @@ func (l *Label) GetConfig() (cfg LabelConfig) {
 	return
 }
 
+func (l *Label) setData(data LabelConfig) {
+	l.data = data
+	l.runs = nil
+	l.text = data.Text
+	if data.Message != nil {
+		if t := data.Message.String(); t != data.Message.Key {
+			l.text = t
+		}
+	}
+	defer func() {
+		name := l.text
+		if l.runs != nil {
+			name = uik.RunsText(l.runs)
+		}
+		l.SetAccessible(uik.Accessible{
+			Role: uik.RoleLabel,
+			Name: name,
+		})
+	}()
+	if !data.Markup {
+		return
+	}
+	runs, err := uik.ParseMarkup(l.text)
+	if err != nil {
+		log.Print("Label ", l.ID, ": ", err)
+		return
+	}
+	l.runs = runs
+}
+
+// fits reports whether the label's text is laid out to its width, rather
+// than deciding it.
+func (l *Label) fits() bool {
+	return l.data.Wrap != uik.WrapNone || l.data.Ellipsis
+}
+
+// paragraph is the label's text at the given width, in units.
+func (l *Label) paragraph(width float64) (p uik.Paragraph) {
+	p.Text = l.text
+	p.Runs = l.runs
+	p.Font = l.StyleFont("text")
+	p.Size = l.data.FontSize
+	if p.Size == 0 {
+		p.Size = l.StyleMetric("text.size")
+	}
+	if l.fits() {
+		p.Width = width
+	}
+	p.Wrap = l.data.Wrap
+	p.Align = l.data.Align
+	p.Direction = l.data.Direction
+	p.LineSpacing = l.data.LineSpacing
+	p.MaxLines = l.data.MaxLines
+	p.Ellipsis = l.data.Ellipsis
+	return
+}
+
+// render the text at the physical resolution of the label
 func (l *Label) render() {
-	l.tbuf = uik.RenderString(l.data.Text, uik.DefaultFontData, l.data.FontSize, l.data.Color)
-	s := geom.Coord{float64(l.tbuf.Bounds().Max.X), float64(l.tbuf.Bounds().Max.Y)}
+	textColor := l.data.Color
+	if textColor == nil {
+		textColor = l.StyleColor("text")
+	}
+
+	p := l.paragraph(l.Size.X).Scaled(l.Scale)
+	l.tbuf = uik.LayoutText(p).Render(textColor)
 
 	// go uik.ShowBuffer("label text render", l.tbuf)
 
-	l.SetSizeHint(uik.SizeHint{
-		MinSize:       s,
-		PreferredSize: s,
-		MaxSize:       s,
-	})
+	l.SetSizeHint(l.sizeHint())
+}
+
+func (l *Label) sizeHint() (sh uik.SizeHint) {
+	natural := uik.LayoutText(l.paragraph(0))
+	s := geom.Coord{natural.Width, natural.Height}
+	if !l.fits() {
+		sh.MinSize, sh.PreferredSize, sh.MaxSize = s, s, s
+		return
+	}
+
+	// as narrow as one character, or the widest word
+	p := l.paragraph(1)
+	if l.data.Wrap == uik.WrapWord && !l.data.Ellipsis {
+		p.Wrap, p.Ellipsis = uik.WrapNone, false
+		if p.Runs != nil {
+			// close enough, without the styles
+			p.Text, p.Runs = uik.RunsText(p.Runs), nil
+		}
+		p.Text = strings.Join(strings.Fields(p.Text), "\n")
+		p.Width = 0
+	}
+	narrow := uik.LayoutText(p)
+	sh.MinSize.Y = natural.LineHeight
+	for _, line := range narrow.Lines {
+		sh.MinSize.X = math.Max(sh.MinSize.X, line.Width)
+	}
+
+	sh.PreferredSize = s
+	if l.Size.X > 0 {
+		sh.PreferredSize.Y = uik.LayoutText(l.paragraph(l.Size.X)).Height
+	}
+	sh.MaxSize = geom.Coord{math.Inf(1), math.Inf(1)}
+
+	cfg := l.paragraph(0)
+	wrap, ellipsis := l.data.Wrap, l.data.Ellipsis
+	sh.HeightForWidth = func(width float64) float64 {
+		p := cfg
+		p.Width, p.Wrap, p.Ellipsis = width, wrap, ellipsis
+		return uik.LayoutText(p).Height
+	}
+	return
 }
 
 func (l *Label) draw(gc draw2d.GraphicContext) {
@@ -99,17 +232,49 @@ func (l *Label) draw(gc draw2d.GraphicContext) {
 	// gc.SetFillColor(color.RGBA{A: 1})
 	// safeRect(gc, geom.Coord{0, 0}, l.Size)
 	// gc.Fill()
-	tw := float64(l.tbuf.Bounds().Max.X - l.tbuf.Bounds().Min.X)
-	th := float64(l.tbuf.Bounds().Max.Y - l.tbuf.Bounds().Min.Y)
+	tw := float64(l.tbuf.Bounds().Max.X-l.tbuf.Bounds().Min.X) / l.Scale
+	th := float64(l.tbuf.Bounds().Max.Y-l.tbuf.Bounds().Min.Y) / l.Scale
 	gc.Translate((l.Size.X-tw)/2, (l.Size.Y-th)/2)
+	gc.Scale(1/l.Scale, 1/l.Scale)
 	gc.DrawImage(l.tbuf)
 }
 
+func (l *Label) updateData(data LabelConfig) {
+	if l.data == data {
+		return
+	}
+	l.setData(data)
+	l.render()
+	l.Invalidate()
+}
+
+// BindText shows v, formatted with fmt.Sprint, as the label's text, until
+// unbind is called.
+func (l *Label) BindText(v uik.Observable) (unbind func()) {
+	stop := v.Watch(l.UserEventsIn, func() {
+		data := l.data
+		data.Text = fmt.Sprint(v.Interface())
+		l.updateData(data)
+	})
+	unbind = l.TrackBinding(stop)
+	return
+}
+
 func (l *Label) handleEvents() {
 	for {
 		select {
 		case e := <-l.UserEvents:
 			switch e := e.(type) {
+			case uik.ScaleEvent, uik.ThemeEvent:
+				l.HandleEvent(e)
+				l.render()
+			case uik.LocaleEvent:
+				if l.data.Message == nil {
+					break
+				}
+				l.setData(l.data)
+				l.render()
+				l.Invalidate()
 			default:
 				l.HandleEvent(e)
 			}
@@ -117,16 +282,16 @@ func (l *Label) handleEvents() {
 			if l.Size == e.Size {
 				break
 			}
+			resized := l.Size.X != e.Size.X
 			l.Block.DoResizeEvent(e)
+			if resized && l.fits() {
+				// the text needs laying out again for the new width
+				l.render()
+			}
 			l.Invalidate()
 			// go uik.ShowBuffer("label buffer", l.Buffer)
 		case data := <-l.setConfig:
-			if l.data == data {
-				break
-			}
-			l.data = data
-			l.render()
-			l.Invalidate()
+			l.updateData(data)
 			// go uik.ShowBuffer("label buffer", l.Buffer)
 		case l.getConfig <- l.data:
 			// go uik.ShowBuffer("label buffer", l.Buffer)
@@ -20,7 +20,6 @@ import (
 	"code.google.com/p/draw2d/draw2d"
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
-	"image/color"
 )
 
 func init() {
@@ -35,19 +34,13 @@ func init() {
 			}
 
 			// gc.SetStrokeColor(color.Black)
-			if b.pressed {
-				gc.SetFillColor(color.RGBA{50, 50, 50, 255})
-				safeRect(gc, bbounds.Min, bbounds.Max)
-				gc.Fill()
+			if b.config.Color != nil && !b.pressed {
+				gc.SetFillColor(b.config.Color)
 			} else {
-				if b.config.Color != nil {
-					gc.SetFillColor(b.config.Color)
-				} else {
-					gc.SetFillColor(color.RGBA{200, 200, 200, 255})
-				}
-				safeRect(gc, bbounds.Min, bbounds.Max)
-				gc.Fill()
+				gc.SetFillColor(b.StyleColor("background"))
 			}
+			safeRect(gc, bbounds.Min, bbounds.Max)
+			gc.Fill()
 		}
 	})
 
@@ -55,15 +48,7 @@ func init() {
 		c := x.(*Checkbox)
 		return func(gc draw2d.GraphicContext) {
 			gc.Clear()
-			if c.pressed {
-				if c.pressHover {
-					gc.SetFillColor(color.RGBA{200, 0, 0, 255})
-				} else {
-					gc.SetFillColor(color.RGBA{155, 0, 0, 255})
-				}
-			} else {
-				gc.SetFillColor(color.RGBA{255, 0, 0, 255})
-			}
+			gc.SetFillColor(c.StyleColor("background"))
 
 			// Draw background rect
 			x, y := gc.LastPoint()
@@ -76,11 +61,12 @@ func init() {
 
 			// Draw inner rect
 			if c.state {
-				gc.SetFillColor(color.Black)
-				gc.MoveTo(5, 5)
-				gc.LineTo(c.Size.X-5, 5)
-				gc.LineTo(c.Size.X-5, c.Size.Y-5)
-				gc.LineTo(5, c.Size.Y-5)
+				inset := c.StyleMetric("inset")
+				gc.SetFillColor(c.StyleColor("check"))
+				gc.MoveTo(inset, inset)
+				gc.LineTo(c.Size.X-inset, inset)
+				gc.LineTo(c.Size.X-inset, c.Size.Y-inset)
+				gc.LineTo(inset, c.Size.Y-inset)
 				gc.Close()
 				gc.Fill()
 			}
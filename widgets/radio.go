@@ -18,11 +18,11 @@ package widgets
 
 import (
 	"code.google.com/p/draw2d/draw2d"
+	"fmt"
 	"github.com/skelterjohn/geom"
 	"github.com/skelterjohn/go.uik"
 	"github.com/skelterjohn/go.uik/layouts"
 	"github.com/skelterjohn/go.wde"
-	"image/color"
 )
 
 type RadioSelection struct {
@@ -30,12 +30,12 @@ type RadioSelection struct {
 	Option string
 }
 
-type SelectionListener chan RadioSelection
-
 type Radio struct {
 	uik.Foundation
 
-	options    []string
+	options []string
+	// if not nil, what the options are translated from
+	messages   []*uik.Message
 	setOptions chan []string
 	SetOptions chan<- []string
 	getOptions chan []string
@@ -47,17 +47,15 @@ type Radio struct {
 	getSelection chan int
 	GetSelection <-chan int
 
-	buttons     []*Button
-	buttonsDone []chan bool
+	buttons []*Button
 
-	selectionListeners      map[SelectionListener]bool
-	addSelectionListener    chan SelectionListener
-	AddSelectionListener    chan<- SelectionListener
-	removeSelectionListener chan SelectionListener
-	RemoveSelectionListener <-chan SelectionListener
+	// Selected gets the option chosen, whenever it changes.
+	Selected uik.Signal[RadioSelection]
 
 	radioGrid   *layouts.GridEngine
 	radioLayout *layouts.Layouter
+
+	boundSelection *uik.IntValue
 }
 
 func NewRadio(options []string) (r *Radio) {
@@ -77,6 +75,7 @@ func NewRadio(options []string) (r *Radio) {
 
 func (r *Radio) Initialize() {
 	r.Foundation.Initialize()
+	r.Type = "widgets.Radio"
 
 	r.setOptions = make(chan []string, 1)
 	r.SetOptions = r.setOptions
@@ -84,12 +83,6 @@ func (r *Radio) Initialize() {
 	r.setSelection = make(chan int, 1)
 	r.SetSelection = r.setSelection
 
-	r.selectionListeners = map[SelectionListener]bool{}
-	r.addSelectionListener = make(chan SelectionListener, 1)
-	r.AddSelectionListener = r.addSelectionListener
-	r.removeSelectionListener = make(chan SelectionListener, 1)
-	r.RemoveSelectionListener = r.removeSelectionListener
-
 	r.selection = -1
 
 	r.radioGrid = layouts.NewGridEngine(layouts.GridConfig{})
@@ -99,7 +92,7 @@ func (r *Radio) Initialize() {
 	r.radioLayout.Paint = nil
 
 	r.Paint = func(gc draw2d.GraphicContext) {
-		gc.SetFillColor(color.Black)
+		gc.SetFillColor(r.StyleColor("background"))
 		bbounds := r.Bounds()
 		safeRect(gc, bbounds.Min, bbounds.Max)
 		gc.Fill()
@@ -115,15 +108,10 @@ func (r *Radio) HandleEvents() {
 			r.Foundation.DoResizeEvent(e)
 			r.PlaceBlock(&r.radioLayout.Block, geom.Rect{Max: r.Size})
 		case options := <-r.setOptions:
+			r.messages = nil
 			r.makeButtons(options)
 		case r.selection = <-r.setSelection:
-			r.updateButtons()
-			for selLis := range r.selectionListeners {
-				selLis <- RadioSelection{
-					Index:  r.selection,
-					Option: r.options[r.selection],
-				}
-			}
+			r.selectionChanged()
 		case r.getSelection <- r.selection:
 		case bsh := <-r.BlockSizeHints:
 			r.ChildrenHints[bsh.Block] = bsh.SizeHint
@@ -136,23 +124,107 @@ func (r *Radio) HandleEvents() {
 			r.SetSizeHint(bsh.SizeHint)
 		case inv := <-r.BlockInvalidations:
 			r.Invalidate(inv.Bounds...)
-		case selLis := <-r.addSelectionListener:
-			r.selectionListeners[selLis] = true
-		case selLis := <-r.removeSelectionListener:
-			if r.selectionListeners[selLis] {
-				delete(r.selectionListeners, selLis)
-			}
 		}
 	}
 }
 
 func (r *Radio) HandleEvent(e interface{}) {
 	switch e := e.(type) {
+	case uik.ThemeEvent:
+		r.Foundation.HandleEvent(e)
+		r.updateButtons()
+	case uik.LocaleEvent:
+		r.Foundation.HandleEvent(e)
+		if r.messages != nil {
+			r.makeButtons(translateAll(r.messages))
+		}
+	case uik.AccessActionEvent:
+		if e.Action != "select" {
+			break
+		}
+		for i, option := range r.options {
+			if option == e.Value {
+				r.selection = i
+				r.selectionChanged()
+				break
+			}
+		}
 	default:
 		r.Foundation.HandleEvent(e)
 	}
 }
 
+func (r *Radio) selectionChanged() {
+	r.updateButtons()
+	if r.boundSelection != nil {
+		r.boundSelection.Set(r.selection)
+	}
+	r.Selected.Emit(RadioSelection{
+		Index:  r.selection,
+		Option: r.options[r.selection],
+	})
+}
+
+// BindSelection selects the option at index v, and sets v when another
+// option is chosen, until unbind is called.
+func (r *Radio) BindSelection(v *uik.IntValue) (unbind func()) {
+	stop := v.Watch(r.UserEventsIn, func() {
+		r.boundSelection = v
+		i := v.Get()
+		if i == r.selection || i < 0 || i >= len(r.options) {
+			return
+		}
+		r.selection = i
+		r.selectionChanged()
+	})
+	unbind = r.TrackBinding(func() {
+		stop()
+		r.UserEventsIn <- uik.BindingEvent{
+			Apply: func() {
+				if r.boundSelection == v {
+					r.boundSelection = nil
+				}
+			},
+		}
+	})
+	return
+}
+
+// BindOptions shows the items of v, formatted with fmt.Sprint, as the
+// options, until unbind is called.
+func (r *Radio) BindOptions(v *uik.ListValue) (unbind func()) {
+	stop := v.Watch(r.UserEventsIn, func() {
+		items := v.Get()
+		options := make([]string, len(items))
+		for i, item := range items {
+			options[i] = fmt.Sprint(item)
+		}
+		r.messages = nil
+		r.makeButtons(options)
+	})
+	unbind = r.TrackBinding(stop)
+	return
+}
+
+// SetOptionMessages makes the options the messages, looked up in
+// uik.Translations, so that they follow changes of locale.
+func (r *Radio) SetOptionMessages(messages []*uik.Message) {
+	r.UserEventsIn <- uik.BindingEvent{
+		Apply: func() {
+			r.messages = messages
+			r.makeButtons(translateAll(messages))
+		},
+	}
+}
+
+func translateAll(messages []*uik.Message) (texts []string) {
+	texts = make([]string, len(messages))
+	for i, m := range messages {
+		texts[i] = m.String()
+	}
+	return
+}
+
 func (r *Radio) makeButtons(options []string) {
 	// see if the options are actually different
 	changed := len(r.options) != len(options)
@@ -168,24 +240,21 @@ func (r *Radio) makeButtons(options []string) {
 	}
 	r.options = options
 
-	// remove old buttons
+	// remove old buttons, which stops their clicks being listened to
 	for _, b := range r.buttons {
 		r.RemoveBlock(&b.Block)
-	}
-	for _, d := range r.buttonsDone {
-		d <- true
+		b.Clicked.Close()
 	}
 
 	r.buttons = make([]*Button, len(r.options))
-	r.buttonsDone = make([]chan bool, len(r.options))
 	for i, option := range r.options {
 		ob := NewButton(option)
 		r.buttons[i] = ob
-		r.buttonsDone[i] = make(chan bool, 1)
 
+		spacing := r.StyleMetric("spacing")
 		pb := layouts.NewPadBox(layouts.PadConfig{
-			Left: 2, Right: 2,
-			Top: 2, Bottom: 2,
+			Left: spacing, Right: spacing,
+			Top: spacing, Bottom: spacing,
 		}, &ob.Block)
 
 		r.radioGrid.Add(&pb.Block, layouts.GridComponent{
@@ -196,27 +265,35 @@ func (r *Radio) makeButtons(options []string) {
 			AnchorBottom: true,
 		})
 
-		clicker := make(chan wde.Button, 1)
-		go func(clicker chan wde.Button, index int, done chan bool) {
-			for {
-				select {
-				case <-clicker:
-					r.SetSelection <- index
-				case <-done:
-					return
-				}
+		clicks := ob.Clicked.Listen(uik.DeliverDrop, 1)
+		go func(clicks <-chan wde.Button, index int) {
+			for _ = range clicks {
+				r.SetSelection <- index
 			}
-		}(clicker, i, r.buttonsDone[i])
-		ob.AddClicker <- clicker
+		}(clicks.C, i)
 	}
 	r.updateButtons()
 }
 
+// publish tells the accessibility tree which option is selected.
+func (r *Radio) publish() {
+	a := uik.Accessible{
+		Role:    uik.RoleRadioGroup,
+		Name:    r.Name,
+		Actions: []string{"select"},
+	}
+	if r.selection >= 0 && r.selection < len(r.options) {
+		a.Value = r.options[r.selection]
+	}
+	r.SetAccessible(a)
+}
+
 func (r *Radio) updateButtons() {
+	r.publish()
 	for i, b := range r.buttons {
 		if i == r.selection {
 			b.SetConfig(ButtonConfig{
-				Color: color.RGBA{110, 110, 110, 255},
+				Color: r.StyleColor("selected"),
 			})
 		} else {
 			b.SetConfig(ButtonConfig{})
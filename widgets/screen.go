@@ -0,0 +1,685 @@
+/*
+   Copyright 2012 the go.uik authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package widgets
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/skelterjohn/geom"
+	"github.com/skelterjohn/go.uik"
+	"github.com/skelterjohn/go.uik/layouts"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A ScreenNode describes a layout or widget, and the nodes inside it. Which
+// fields are used depends on Type:
+//
+//	Grid      Grid, and each child's Cell or Component
+//	HBox      Grid, with children in a row
+//	VBox      Grid, with children in a column
+//	Flow      RightToLeft
+//	PadBox    Pad, and exactly one child
+//	Button    Text
+//	Label     Size, Text, Markup, FontSize, Color, Wrap, Align, MaxLines,
+//	          Ellipsis
+//	Checkbox  Size, Checked
+//	Entry     Size, Text
+//	Radio     Options, Selected
+//	Image     Image, a file path, and Description
+//
+// Named nodes can be found in the Screen that is built, and have their
+// block's Name set, for style sheets. A label's Size is only the size it
+// starts at, before its text and layout decide it, so Apply doesn't change
+// it for labels already built.
+type ScreenNode struct {
+	Type     string
+	Name     string        `json:",omitempty"`
+	Children []*ScreenNode `json:",omitempty"`
+
+	// Where a child of a Grid goes: the component of the grid's config
+	// with the name Cell, or else Component.
+	Cell      string                 `json:",omitempty"`
+	Component *layouts.GridComponent `json:",omitempty"`
+
+	Grid        layouts.GridConfig
+	Pad         layouts.PadConfig
+	RightToLeft bool `json:",omitempty"`
+
+	Size        geom.Coord
+	Text        string        `json:",omitempty"`
+	Markup      bool          `json:",omitempty"`
+	FontSize    float64       `json:",omitempty"`
+	Color       string        `json:",omitempty"`
+	Wrap        uik.WrapMode  `json:",omitempty"`
+	Align       uik.Alignment `json:",omitempty"`
+	MaxLines    int           `json:",omitempty"`
+	Ellipsis    bool          `json:",omitempty"`
+	Checked     bool          `json:",omitempty"`
+	Options     []string      `json:",omitempty"`
+	Selected    *int          `json:",omitempty"`
+	Image       string        `json:",omitempty"`
+	Description string        `json:",omitempty"`
+}
+
+// A Screen is a tree of blocks built from ScreenNodes. It is safe for use
+// from multiple goroutines.
+type Screen struct {
+	Root *uik.Block
+
+	mu      sync.Mutex
+	root    *screenBlock
+	blocks  map[string]*uik.Block
+	widgets map[string]interface{}
+
+	// relative image paths are found from here
+	dir string
+}
+
+// A screenBlock is a node as it was built.
+type screenBlock struct {
+	desc   *ScreenNode
+	block  *uik.Block
+	widget interface{}
+	// for grids, boxes and pad boxes, what lays their children out
+	grid     *layouts.GridEngine
+	pad      *layouts.PadLayout
+	flow     *layouts.Flow
+	children []*screenBlock
+}
+
+func ReadScreen(r io.Reader) (s *Screen, err error) {
+	root, err := readScreenNode(r)
+	if err != nil {
+		return
+	}
+	s, err = BuildScreen(root)
+	return
+}
+
+func ParseScreen(desc string) (s *Screen, err error) {
+	s, err = ReadScreen(strings.NewReader(desc))
+	return
+}
+
+func readScreenNode(r io.Reader) (root *ScreenNode, err error) {
+	root = new(ScreenNode)
+	dec := json.NewDecoder(r)
+	err = dec.Decode(root)
+	return
+}
+
+func loadScreenNode(path string) (root *ScreenNode, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	root, err = readScreenNode(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// LoadScreen builds the screen described in a file. Image paths in it are
+// relative to the file.
+func LoadScreen(path string) (s *Screen, err error) {
+	root, err := loadScreenNode(path)
+	if err != nil {
+		return
+	}
+	s = newScreen(filepath.Dir(path))
+	if err = s.apply(root); err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// BuildScreen makes the blocks root describes.
+func BuildScreen(root *ScreenNode) (s *Screen, err error) {
+	s = newScreen("")
+	err = s.apply(root)
+	return
+}
+
+// WatchScreen loads the screen described in a file, and applies the file
+// to it again whenever it changes. Errors in later versions of the file
+// are logged, and leave the screen as it was. Call stop to stop watching.
+func WatchScreen(path string) (s *Screen, stop func(), err error) {
+	s, err = LoadScreen(path)
+	if err != nil {
+		return
+	}
+	stop = uik.WatchFile(path, uik.WatchInterval, func() {
+		root, err := loadScreenNode(path)
+		if err == nil {
+			err = s.Apply(root)
+		}
+		if err != nil {
+			log.Print("screen ", path, ": ", err)
+		}
+	})
+	return
+}
+
+func newScreen(dir string) (s *Screen) {
+	s = new(Screen)
+	s.dir = dir
+	return
+}
+
+// Apply changes the screen to match root. Nodes that are still described,
+// matched by name, or by type and order if they have none, keep their
+// blocks, so widgets keep their state, such as an entry's text or a
+// checkbox's check, and are only moved or reconfigured. Other blocks are
+// removed, and new nodes are built. The root must keep its type.
+func (s *Screen) Apply(root *ScreenNode) (err error) {
+	err = s.apply(root)
+	return
+}
+
+func (s *Screen) apply(root *ScreenNode) (err error) {
+	if err = s.check(root, map[string]bool{}); err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.root == nil {
+		s.root, err = s.build(root)
+		if err != nil {
+			return
+		}
+		s.Root = s.root.block
+	} else {
+		if root.Type != s.root.desc.Type {
+			err = fmt.Errorf("screen: the root can't change from %s to %s", s.root.desc.Type, root.Type)
+			return
+		}
+		err = s.update(s.root, root)
+	}
+	s.blocks = map[string]*uik.Block{}
+	s.widgets = map[string]interface{}{}
+	s.index(s.root)
+	return
+}
+
+func (s *Screen) index(sb *screenBlock) {
+	if name := sb.desc.Name; name != "" {
+		s.blocks[name] = sb.block
+		s.widgets[name] = sb.widget
+	}
+	for _, c := range sb.children {
+		s.index(c)
+	}
+}
+
+// check finds mistakes in the description before anything is built.
+func (s *Screen) check(n *ScreenNode, names map[string]bool) (err error) {
+	if n.Name != "" {
+		if names[n.Name] {
+			return fmt.Errorf("screen: more than one node is named %q", n.Name)
+		}
+		names[n.Name] = true
+	}
+	switch n.Type {
+	case "Grid":
+		for _, child := range n.Children {
+			if child.Component != nil {
+				continue
+			}
+			if child.Cell == "" {
+				return fmt.Errorf("screen: %s %q in grid %q needs a Cell or Component", child.Type, child.Name, n.Name)
+			}
+			if _, ok := n.Grid.Components[child.Cell]; !ok {
+				return fmt.Errorf("screen: grid %q has no cell %q", n.Name, child.Cell)
+			}
+		}
+	case "HBox", "VBox", "Flow":
+	case "PadBox":
+		if len(n.Children) != 1 {
+			return fmt.Errorf("screen: PadBox %q has %d children, not 1", n.Name, len(n.Children))
+		}
+	case "Button", "Label", "Checkbox", "Entry", "Radio", "Image":
+		if len(n.Children) != 0 {
+			return fmt.Errorf("screen: %s %q can't have children", n.Type, n.Name)
+		}
+	default:
+		return fmt.Errorf("screen: unknown type %q", n.Type)
+	}
+	if n.Color != "" {
+		if _, err = uik.ParseColor(n.Color); err != nil {
+			return
+		}
+	}
+	if sel := n.Selected; sel != nil && (*sel < 0 || *sel >= len(n.Options)) {
+		return fmt.Errorf("screen: radio %q has no option %d", n.Name, *sel)
+	}
+	for _, child := range n.Children {
+		if err = s.check(child, names); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *Screen) build(n *ScreenNode) (sb *screenBlock, err error) {
+	sb = &screenBlock{desc: n}
+	for _, child := range n.Children {
+		var csb *screenBlock
+		if csb, err = s.build(child); err != nil {
+			return
+		}
+		sb.children = append(sb.children, csb)
+	}
+
+	switch n.Type {
+	case "Grid", "HBox", "VBox":
+		sb.grid = layouts.NewGridEngine(n.Grid)
+		l := layouts.NewLayouter(sb.grid)
+		sb.block, sb.widget = &l.Block, l
+		for i, csb := range sb.children {
+			placeInGrid(sb, i, csb)
+		}
+	case "Flow":
+		sb.flow = layouts.NewFlow()
+		sb.flow.SetRightToLeft <- n.RightToLeft
+		for _, csb := range sb.children {
+			sb.flow.Add <- csb.block
+		}
+		sb.block, sb.widget = &sb.flow.Block, sb.flow
+	case "PadBox":
+		sb.pad = layouts.NewPadLayout(n.Pad, sb.children[0].block)
+		l := layouts.NewLayouter(sb.pad)
+		sb.block, sb.widget = &l.Block, l
+	default:
+		err = s.buildWidget(sb)
+	}
+	if err != nil {
+		return
+	}
+	sb.block.Name = n.Name
+	return
+}
+
+// placeInGrid puts the ith child of a grid or box where it is described.
+func placeInGrid(sb *screenBlock, i int, child *screenBlock) {
+	switch sb.desc.Type {
+	case "HBox":
+		sb.grid.Add(child.block, layouts.GridComponent{
+			GridX: i, GridY: 0,
+			AnchorTop: true,
+		})
+	case "VBox":
+		sb.grid.Add(child.block, layouts.GridComponent{
+			GridX: 0, GridY: i,
+			AnchorLeft: true,
+		})
+	default:
+		if child.desc.Component != nil {
+			sb.grid.Add(child.block, *child.desc.Component)
+		} else {
+			sb.grid.AddName(child.desc.Cell, child.block)
+		}
+	}
+}
+
+func labelConfig(n *ScreenNode) (cfg LabelConfig) {
+	cfg = LabelConfig{
+		Text:     n.Text,
+		Markup:   n.Markup,
+		FontSize: n.FontSize,
+		Wrap:     n.Wrap,
+		Align:    n.Align,
+		MaxLines: n.MaxLines,
+		Ellipsis: n.Ellipsis,
+	}
+	if n.Color != "" {
+		// already checked
+		cfg.Color, _ = uik.ParseColor(n.Color)
+	}
+	return
+}
+
+func (s *Screen) buildWidget(sb *screenBlock) (err error) {
+	n := sb.desc
+	switch n.Type {
+	case "Button":
+		w := NewButton(n.Text)
+		sb.block, sb.widget = &w.Block, w
+	case "Label":
+		w := NewLabel(n.Size, labelConfig(n))
+		sb.block, sb.widget = &w.Block, w
+	case "Checkbox":
+		w := NewCheckbox(n.Size)
+		if n.Checked {
+			w.UserEventsIn <- uik.BindingEvent{
+				Apply: func() {
+					w.setChecked(true)
+				},
+			}
+		}
+		sb.block, sb.widget = &w.Block, w
+	case "Entry":
+		w := NewEntry(n.Size)
+		if n.Text != "" {
+			w.SetText(n.Text)
+		}
+		sb.block, sb.widget = &w.Block, w
+	case "Radio":
+		w := NewRadio(n.Options)
+		if n.Selected != nil {
+			selectRadio(w, n.Options, *n.Selected)
+		}
+		sb.block, sb.widget = &w.Block, w
+	case "Image":
+		var cfg ImageConfig
+		cfg.Description = n.Description
+		if cfg.Image, err = s.loadImage(n.Image); err != nil {
+			return
+		}
+		w := NewImage(cfg)
+		sb.block, sb.widget = &w.Block, w
+	}
+	return
+}
+
+// A screenUpdate is how a built node will change to match a new
+// description. Everything that can fail, building new nodes and loading
+// images, is done while planning it, so that the blocks already shown are
+// only changed once nothing can go wrong.
+type screenUpdate struct {
+	sb *screenBlock
+	n  *ScreenNode
+	// the children sb will have, and the updates of those it has already
+	children []*screenBlock
+	updates  []*screenUpdate
+	removed  []*screenBlock
+	// for an image whose file has changed
+	image image.Image
+}
+
+// update changes what sb shows to match n, which has the same type.
+func (s *Screen) update(sb *screenBlock, n *ScreenNode) (err error) {
+	u, err := s.plan(sb, n)
+	if err != nil {
+		return
+	}
+	s.commit(u)
+	return
+}
+
+// plan matches the nodes of n with the children of sb, planning updates
+// for those that match and building the rest. The children of sb that
+// match nothing will be removed. Nothing already shown is changed.
+func (s *Screen) plan(sb *screenBlock, n *ScreenNode) (u *screenUpdate, err error) {
+	u = &screenUpdate{sb: sb, n: n}
+	used := map[*screenBlock]bool{}
+	for _, cn := range n.Children {
+		var match *screenBlock
+		for _, c := range sb.children {
+			if used[c] || c.desc.Type != cn.Type || c.desc.Name != cn.Name {
+				continue
+			}
+			match = c
+			break
+		}
+		if match != nil {
+			used[match] = true
+			var cu *screenUpdate
+			if cu, err = s.plan(match, cn); err != nil {
+				return
+			}
+			u.updates = append(u.updates, cu)
+		} else if match, err = s.build(cn); err != nil {
+			return
+		}
+		u.children = append(u.children, match)
+	}
+	for _, c := range sb.children {
+		if !used[c] {
+			u.removed = append(u.removed, c)
+		}
+	}
+	old := sb.desc
+	if n.Type == "Image" && (n.Image != old.Image || n.Description != old.Description) {
+		u.image, err = s.loadImage(n.Image)
+	}
+	return
+}
+
+// commit carries out an update that has been planned.
+func (s *Screen) commit(u *screenUpdate) {
+	sb, n, children := u.sb, u.n, u.children
+	old := sb.desc
+	sb.desc = n
+	sb.block.Name = n.Name
+
+	for _, cu := range u.updates {
+		s.commit(cu)
+	}
+
+	switch n.Type {
+	case "Grid", "HBox", "VBox":
+		if !reflect.DeepEqual(old.Grid, n.Grid) {
+			sb.grid.SetConfig(n.Grid)
+		}
+		for _, c := range u.removed {
+			sb.grid.Remove(c.block)
+		}
+		for i, c := range children {
+			placeInGrid(sb, i, c)
+		}
+	case "Flow":
+		if old.RightToLeft != n.RightToLeft {
+			sb.flow.SetRightToLeft <- n.RightToLeft
+		}
+		reordered := len(children) != len(sb.children)
+		for i := 0; !reordered && i < len(children); i++ {
+			reordered = children[i] != sb.children[i]
+		}
+		if reordered {
+			// a flow can only add to the end, so start again
+			for _, c := range sb.children {
+				sb.flow.Remove <- c.block
+			}
+			for _, c := range children {
+				sb.flow.Add <- c.block
+			}
+		}
+	case "PadBox":
+		if children[0] != sb.children[0] {
+			sb.pad.SetBlock(children[0].block)
+		}
+		if old.Pad != n.Pad {
+			sb.pad.SetConfig(n.Pad)
+		}
+	default:
+		s.updateWidget(sb, old, u.image)
+	}
+	for _, c := range u.removed {
+		discard(c)
+	}
+	sb.children = children
+}
+
+// discard closes the signals of the widgets in sb, which has been removed
+// for good, so that their listeners end, undoes their bindings, and takes
+// them out of the accessibility tree.
+func discard(sb *screenBlock) {
+	uik.Accessibility.Forget(sb.block)
+	sb.block.UnbindAll()
+	switch w := sb.widget.(type) {
+	case *Button:
+		w.Clicked.Close()
+	case *Checkbox:
+		w.Toggled.Close()
+	case *Entry:
+		w.Changed.Close()
+		w.Submitted.Close()
+	case *Radio:
+		w.Selected.Close()
+	}
+	for _, c := range sb.children {
+		discard(c)
+	}
+}
+
+// updateWidget reconfigures a widget whose description has changed from
+// old. Only what the description changes is set, so what the user may
+// have changed since, like an entry's text, is kept.
+func (s *Screen) updateWidget(sb *screenBlock, old *ScreenNode, img image.Image) {
+	n := sb.desc
+	switch w := sb.widget.(type) {
+	case *Button:
+		if n.Text != old.Text {
+			cfg := w.Label.GetConfig()
+			cfg.Text = n.Text
+			w.Label.SetConfig(cfg)
+		}
+	case *Label:
+		if labelConfig(n) != labelConfig(old) {
+			w.SetConfig(labelConfig(n))
+		}
+	case *Checkbox:
+		if n.Size != old.Size {
+			fixSize(&w.Block, n.Size)
+		}
+		if n.Checked != old.Checked {
+			checked := n.Checked
+			w.UserEventsIn <- uik.BindingEvent{
+				Apply: func() {
+					w.setChecked(checked)
+				},
+			}
+		}
+	case *Entry:
+		if n.Size != old.Size {
+			fixSize(&w.Block, n.Size)
+		}
+	case *Radio:
+		changed := !reflect.DeepEqual(n.Options, old.Options)
+		if n.Selected != nil && (old.Selected == nil || *n.Selected != *old.Selected) {
+			selectRadio(w, n.Options, *n.Selected)
+		} else if changed {
+			w.SetOptions <- n.Options
+		}
+	case *Image:
+		if img == nil {
+			break
+		}
+		w.SetConfig(ImageConfig{
+			Image:       img,
+			Description: n.Description,
+		})
+	}
+}
+
+// fixSize makes a widget built with a fixed size, like a checkbox, take
+// another.
+func fixSize(b *uik.Block, size geom.Coord) {
+	b.SetSizeHint(uik.SizeHint{
+		MinSize:       size,
+		PreferredSize: size,
+		MaxSize:       size,
+	})
+}
+
+// selectRadio shows options in r and selects the ith, together, so that the
+// selection can't arrive before the options it is one of.
+func selectRadio(r *Radio, options []string, i int) {
+	r.UserEventsIn <- uik.BindingEvent{
+		Apply: func() {
+			r.makeButtons(options)
+			if i == r.selection {
+				return
+			}
+			r.selection = i
+			r.selectionChanged()
+		},
+	}
+}
+
+func (s *Screen) loadImage(path string) (img image.Image, err error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.dir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	img, _, err = image.Decode(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", path, err)
+	}
+	return
+}
+
+// Block returns the block of the node with the given name, or nil.
+func (s *Screen) Block(name string) *uik.Block {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocks[name]
+}
+
+// Widget returns the widget or layout of the node with the given name,
+// such as a *Button or a *layouts.Layouter, or nil.
+func (s *Screen) Widget(name string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.widgets[name]
+}
+
+func (s *Screen) Button(name string) (b *Button) {
+	b, _ = s.Widget(name).(*Button)
+	return
+}
+
+func (s *Screen) Label(name string) (l *Label) {
+	l, _ = s.Widget(name).(*Label)
+	return
+}
+
+func (s *Screen) Checkbox(name string) (c *Checkbox) {
+	c, _ = s.Widget(name).(*Checkbox)
+	return
+}
+
+func (s *Screen) Entry(name string) (e *Entry) {
+	e, _ = s.Widget(name).(*Entry)
+	return
+}
+
+func (s *Screen) Radio(name string) (r *Radio) {
+	r, _ = s.Widget(name).(*Radio)
+	return
+}
+
+func (s *Screen) Image(name string) (i *Image) {
+	i, _ = s.Widget(name).(*Image)
+	return
+}